@@ -0,0 +1,108 @@
+package refstr
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How byte slices and byte arrays are written as strings.
+type BytesEncoding int
+
+const (
+	// The bytes of the string as-is. Byte arrays are written as a list of numbers.
+	BytesRaw BytesEncoding = iota
+	// Hexadecimal, two characters per byte.
+	BytesHex
+	// Standard base64 with padding.
+	BytesBase64
+	// Standard base64 without padding.
+	BytesBase64Raw
+	// URL safe base64 with padding.
+	BytesBase64URL
+	// URL safe base64 without padding.
+	BytesBase64RawURL
+	// Detected by a prefix of hex:, base64: or base64url: and otherwise raw.
+	BytesAuto
+)
+
+// The prefixes recognized by BytesAuto.
+const (
+	BytesHexPrefix       = "hex:"
+	BytesBase64Prefix    = "base64:"
+	BytesBase64URLPrefix = "base64url:"
+)
+
+// Decodes the given string into bytes based on the decoders Bytes encoding.
+// Base64 is accepted with or without padding.
+func (d *Decoder) decodeBytes(s string) ([]byte, error) {
+	switch d.Bytes {
+	case BytesHex:
+		return hex.DecodeString(s)
+	case BytesBase64, BytesBase64Raw:
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	case BytesBase64URL, BytesBase64RawURL:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case BytesAuto:
+		switch {
+		case strings.HasPrefix(s, BytesHexPrefix):
+			return hex.DecodeString(strings.TrimPrefix(s, BytesHexPrefix))
+		case strings.HasPrefix(s, BytesBase64URLPrefix):
+			return base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(s, BytesBase64URLPrefix), "="))
+		case strings.HasPrefix(s, BytesBase64Prefix):
+			return base64.RawStdEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(s, BytesBase64Prefix), "="))
+		}
+	}
+	return []byte(s), nil
+}
+
+// Encodes the given bytes into a string based on the decoders Bytes encoding.
+// BytesAuto writes printable text as-is and everything else as prefixed base64.
+func (d *Decoder) encodeBytes(b []byte) string {
+	switch d.Bytes {
+	case BytesHex:
+		return hex.EncodeToString(b)
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case BytesBase64Raw:
+		return base64.RawStdEncoding.EncodeToString(b)
+	case BytesBase64URL:
+		return base64.URLEncoding.EncodeToString(b)
+	case BytesBase64RawURL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	case BytesAuto:
+		if isPrintable(b) && !hasBytesPrefix(string(b)) {
+			return string(b)
+		}
+		return BytesBase64Prefix + base64.StdEncoding.EncodeToString(b)
+	}
+	return string(b)
+}
+
+// Returns whether the type is a slice of bytes, including named byte slices.
+func isBytes(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8
+}
+
+// Returns whether the bytes are valid UTF-8 made up of only printable characters.
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether the string starts with a prefix recognized by BytesAuto.
+func hasBytesPrefix(s string) bool {
+	return strings.HasPrefix(s, BytesHexPrefix) ||
+		strings.HasPrefix(s, BytesBase64Prefix) ||
+		strings.HasPrefix(s, BytesBase64URLPrefix)
+}
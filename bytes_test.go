@@ -0,0 +1,144 @@
+package refstr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBytesEncoding(t *testing.T) {
+	type hash []byte
+
+	tests := []struct {
+		name     string
+		bytes    BytesEncoding
+		typ      reflect.Type
+		decode   string
+		expected any
+		encoded  string
+		err      bool
+	}{{
+		name:     "raw",
+		bytes:    BytesRaw,
+		typ:      TypeOf[[]byte](),
+		decode:   "abc",
+		expected: []byte("abc"),
+		encoded:  "abc",
+	}, {
+		name:     "raw array",
+		bytes:    BytesRaw,
+		typ:      TypeOf[[2]byte](),
+		decode:   "[1 2]",
+		expected: [2]byte{1, 2},
+		encoded:  "[1 2]",
+	}, {
+		name:     "hex",
+		bytes:    BytesHex,
+		typ:      TypeOf[[]byte](),
+		decode:   "00ff10",
+		expected: []byte{0, 255, 16},
+		encoded:  "00ff10",
+	}, {
+		name:     "hex array",
+		bytes:    BytesHex,
+		typ:      TypeOf[[3]byte](),
+		decode:   "00ff10",
+		expected: [3]byte{0, 255, 16},
+		encoded:  "00ff10",
+	}, {
+		name:     "hex named slice",
+		bytes:    BytesHex,
+		typ:      TypeOf[hash](),
+		decode:   "abcd",
+		expected: hash{0xab, 0xcd},
+		encoded:  "abcd",
+	}, {
+		name:   "hex array wrong length",
+		bytes:  BytesHex,
+		typ:    TypeOf[[2]byte](),
+		decode: "00ff10",
+		err:    true,
+	}, {
+		name:   "hex invalid",
+		bytes:  BytesHex,
+		typ:    TypeOf[[]byte](),
+		decode: "xyz",
+		err:    true,
+	}, {
+		name:     "base64",
+		bytes:    BytesBase64,
+		typ:      TypeOf[[]byte](),
+		decode:   "/+8=",
+		expected: []byte{255, 239},
+		encoded:  "/+8=",
+	}, {
+		name:     "base64 raw",
+		bytes:    BytesBase64Raw,
+		typ:      TypeOf[[]byte](),
+		decode:   "/+8=",
+		expected: []byte{255, 239},
+		encoded:  "/+8",
+	}, {
+		name:     "base64 url",
+		bytes:    BytesBase64URL,
+		typ:      TypeOf[[]byte](),
+		decode:   "_-8",
+		expected: []byte{255, 239},
+		encoded:  "_-8=",
+	}, {
+		name:     "base64 raw url",
+		bytes:    BytesBase64RawURL,
+		typ:      TypeOf[[2]byte](),
+		decode:   "_-8=",
+		expected: [2]byte{255, 239},
+		encoded:  "_-8",
+	}, {
+		name:     "auto raw",
+		bytes:    BytesAuto,
+		typ:      TypeOf[[]byte](),
+		decode:   "hello",
+		expected: []byte("hello"),
+		encoded:  "hello",
+	}, {
+		name:     "auto hex",
+		bytes:    BytesAuto,
+		typ:      TypeOf[[]byte](),
+		decode:   "hex:00ff",
+		expected: []byte{0, 255},
+		encoded:  "base64:AP8=",
+	}, {
+		name:     "auto base64url",
+		bytes:    BytesAuto,
+		typ:      TypeOf[[]byte](),
+		decode:   "base64url:_-8",
+		expected: []byte{255, 239},
+		encoded:  "base64:/+8=",
+	}, {
+		name:     "auto prefix text",
+		bytes:    BytesAuto,
+		typ:      TypeOf[[]byte](),
+		decode:   "base64:aGV4OmE=",
+		expected: []byte("hex:a"),
+		encoded:  "base64:aGV4OmE=",
+	}}
+
+	for _, test := range tests {
+		dec := NewDecoder()
+		dec.Bytes = test.bytes
+
+		val, err := dec.DecodeType(test.typ, test.decode)
+		if (err != nil) != test.err {
+			t.Errorf("[%s] Unexpected error result during DecodeType: %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if !reflect.DeepEqual(val, test.expected) {
+			t.Errorf("[%s] Expected %+v but got %+v", test.name, test.expected, val)
+		}
+		encoded, err := dec.Encode(val)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("[%s] Expected encoded %s but got %s (%v)", test.name, test.encoded, encoded, err)
+		}
+	}
+}
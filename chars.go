@@ -0,0 +1,64 @@
+package refstr
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Whether rune (int32) and byte (uint8) values can be written as characters.
+type CharMode int
+
+const (
+	// Runes and bytes are only numbers.
+	CharsOff CharMode = iota
+	// Runes and bytes can be Go character literals like 'a', '\n' or 'é'.
+	CharsQuoted
+	// Runes and bytes can be character literals or a single non-digit character.
+	CharsBare
+)
+
+// Decodes a character literal (or a bare character if enabled) into a rune.
+// If the string is not a character false is returned so it can be parsed
+// as a number. If single byte is true the character must be ASCII.
+func (d *Decoder) decodeChar(s string, singleByte bool) (rune, bool, error) {
+	var r rune
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		value, _, tail, err := strconv.UnquoteChar(s[1:len(s)-1], '\'')
+		if err != nil {
+			return 0, true, fmt.Errorf("invalid character literal %s: %w", s, err)
+		}
+		if tail != "" {
+			return 0, true, fmt.Errorf("invalid character literal %s: more than one character", s)
+		}
+		r = value
+	} else if d.Chars == CharsBare && utf8.RuneCountInString(s) == 1 {
+		value, _ := utf8.DecodeRuneInString(s)
+		if unicode.IsDigit(value) {
+			return 0, false, nil
+		}
+		r = value
+	} else {
+		return 0, false, nil
+	}
+	if singleByte && r >= utf8.RuneSelf {
+		return 0, true, fmt.Errorf("character %s is not ASCII so does not fit in a byte", s)
+	}
+	return r, true, nil
+}
+
+// Returns the character literal of the rune or false if the literal contains
+// a value or key separator, since it would be split apart when decoded.
+func (d *Decoder) encodeChar(r rune) (string, bool) {
+	quoted := strconv.QuoteRune(r)
+	for _, m := range d.multis() {
+		if m.ValueSeparator != nil && m.ValueSeparator.MatchString(quoted) {
+			return "", false
+		}
+		if m.KeySeparator != nil && m.KeySeparator.MatchString(quoted) {
+			return "", false
+		}
+	}
+	return quoted, true
+}
@@ -3,33 +3,67 @@ package refstr
 import (
 	"fmt"
 	"reflect"
+	"sync/atomic"
 )
 
-var defaultDecoder = NewDecoder()
+var defaultDecoder atomic.Pointer[Decoder]
+
+func init() {
+	SetDefaultDecoder(NewDecoder())
+}
 
 // Decodes the string and applies it to the given v. v must be a pointer.
 func Decode(v any, s string) error {
-	return defaultDecoder.Decode(v, s)
+	return defaultDecoder.Load().Decode(v, s)
 }
 
 // Decodes a value of the given type from the given string and returns it.
 func DecodeType(t reflect.Type, s string) (any, error) {
-	return defaultDecoder.DecodeType(t, s)
+	return defaultDecoder.Load().DecodeType(t, s)
 }
 
 // Parses the string into the given type.
 func Parse(s string, rt reflect.Type) (reflect.Value, error) {
-	return defaultDecoder.Parse(s, rt)
+	return defaultDecoder.Load().Parse(s, rt)
+}
+
+// Encodes the value into a string that can be decoded back into the value.
+func Encode(v any) (string, error) {
+	return defaultDecoder.Load().Encode(v)
+}
+
+// Returns a human readable description of the strings accepted for the type.
+func Describe(rt reflect.Type) string {
+	return defaultDecoder.Load().Describe(rt)
 }
 
 // Converts the value to the given type.
 func Convert(v any, rt reflect.Type) (any, error) {
-	return defaultDecoder.Convert(v, rt)
+	return defaultDecoder.Load().Convert(v, rt)
 }
 
-// Returns the reference to the default decoder to control the global decoding logic.
+// Returns the reference to the default decoder to control the global decoding
+// logic. Changes made through it apply to the package level functions but
+// aren't safe while they're in use by other goroutines.
+//
+// Deprecated: use SetDefaultDecoder with a decoder built with options, and
+// DefaultDecoder to get a copy of the default decoder to start from.
 func GetDefaultDecoder() *Decoder {
-	return &defaultDecoder
+	return defaultDecoder.Load()
+}
+
+// Returns a copy of the default decoder used by the package level functions
+// made with Clone, so changing it doesn't change the default.
+func DefaultDecoder() Decoder {
+	return defaultDecoder.Load().Clone()
+}
+
+// Replaces the default decoder used by the package level functions with a
+// copy of the given decoder made with Clone, so later changes to it don't
+// change the default. It's safe to call while the default is in use.
+func SetDefaultDecoder(d Decoder) {
+	clone := d.Clone()
+	defaultDecoder.Store(&clone)
 }
 
 // Returns a pointer to the given value.
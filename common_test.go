@@ -1,10 +1,32 @@
 package refstr
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 )
 
+// A server in the config tests change.
+type testServer struct {
+	Host string
+	Port int
+}
+
+func (s testServer) Address() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// A config with nested structs, pointers, slices and maps which tests change.
+type testConfig struct {
+	Name    string
+	Server  testServer
+	Backup  *testServer
+	Servers []testServer
+	Limits  map[string]int
+	Groups  map[string]map[string]int
+	Tags    []string
+}
+
 func TestNewConcrete(t *testing.T) {
 	type Point struct{ X, Y float32 }
 
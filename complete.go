@@ -0,0 +1,290 @@
+package refstr
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Returns suggestions for completing a partially typed value of the given
+// type. Each suggestion is the partial value with its last token completed,
+// which could be a bool word, an enum name, a struct field name, or a
+// closing delimiter. Nested multi-valued literals are understood.
+func (d Decoder) Complete(rt reflect.Type, partial string) []string {
+	return d.complete(rt, reflect.Value{}, partial, make(map[reflect.Type]string))
+}
+
+// Returns suggestions for completing a partially typed value for the type of
+// the given value. The value is also used to suggest existing map keys. A nil
+// value has no type so there are no suggestions.
+func (d Decoder) CompleteValue(v any, partial string) []string {
+	rv := Reflect(v)
+	if !rv.IsValid() {
+		return make([]string, 0)
+	}
+	return d.complete(rv.Type(), rv, partial, make(map[reflect.Type]string))
+}
+
+// Returns the completions of s for the given type and optional existing value.
+// The strings the types are already completing are tracked so types which
+// contain themselves don't complete the same string forever.
+func (d *Decoder) complete(rt reflect.Type, rv reflect.Value, s string, completing map[reflect.Type]string) []string {
+	previous, existed := completing[rt]
+	if existed && previous == s {
+		return make([]string, 0)
+	}
+	completing[rt] = s
+	defer func() {
+		if existed {
+			completing[rt] = previous
+		} else {
+			delete(completing, rt)
+		}
+	}()
+
+	plan := d.plan(rt)
+	if rv.IsValid() {
+		rv = Concrete(rv)
+	}
+
+	suggestions := make([]string, 0)
+	for _, e := range plan.enum(d) {
+		suggestions = appendMatch(suggestions, "", s, e.Name)
+	}
+	if plan.unmarshal || plan.parser(d) != nil {
+		return suggestions
+	}
+
+	switch plan.kind {
+	case reflect.Bool:
+		for _, value := range []bool{true, false} {
+			for _, word := range d.boolWords(value) {
+				if word != "" {
+					suggestions = appendMatch(suggestions, "", s, word)
+				}
+			}
+		}
+	case reflect.Slice:
+		if !plan.bytes {
+			suggestions = d.completeList(d.Slice, plan.elem.typ, s, suggestions, completing)
+		}
+	case reflect.Array:
+		if !plan.byteArray || d.Bytes == BytesRaw {
+			suggestions = d.completeList(d.Array, plan.elem.typ, s, suggestions, completing)
+		}
+	case reflect.Map:
+		keyed := strings.HasPrefix(s, d.Map.Start) || (s != "" && strings.HasPrefix(d.Map.Start, s))
+		if plan.set && !keyed {
+			suggestions = d.completeList(d.Slice, plan.key.typ, s, suggestions, completing)
+		} else {
+			suggestions = d.completeKeyed(d.Map, plan, rv, s, suggestions, completing)
+		}
+	case reflect.Struct:
+		suggestions = d.completeKeyed(d.Struct, plan, rv, s, suggestions, completing)
+	}
+
+	return suggestions
+}
+
+// Completes a list of values of the given element type.
+func (d *Decoder) completeList(m Multi, elem reflect.Type, s string, suggestions []string, completing map[reflect.Type]string) []string {
+	prefix, last, ok := d.lastEntry(m, s)
+	if !ok {
+		return appendStart(suggestions, m, s)
+	}
+	if last == "" {
+		suggestions = append(suggestions, prefix+m.End)
+	}
+	for _, sub := range d.complete(elem, reflect.Value{}, last, completing) {
+		suggestions = append(suggestions, prefix+sub)
+	}
+	return suggestions
+}
+
+// Completes the key or value of the last entry of a map or struct.
+func (d *Decoder) completeKeyed(m Multi, plan *decodePlan, rv reflect.Value, s string, suggestions []string, completing map[reflect.Type]string) []string {
+	prefix, last, ok := d.lastEntry(m, s)
+	if !ok {
+		return appendStart(suggestions, m, s)
+	}
+	delimiter := m.KeyDelimiter
+	if delimiter == "" {
+		delimiter = ":"
+	}
+
+	keyEnd := -1
+	if m.KeySeparator != nil {
+		if loc := m.KeySeparator.FindStringIndex(last); loc != nil {
+			keyEnd = loc[0]
+			delimiter = last[loc[0]:loc[1]]
+		}
+	}
+
+	if keyEnd == -1 {
+		if last == "" {
+			suggestions = append(suggestions, prefix+m.End)
+		}
+		for _, key := range d.completionKeys(plan, rv) {
+			suggestions = appendMatch(suggestions, prefix, last, key+delimiter)
+		}
+		if plan.kind == reflect.Map {
+			for _, key := range d.complete(plan.key.typ, reflect.Value{}, last, completing) {
+				suggestions = appendMatch(suggestions, prefix, last, key+delimiter)
+			}
+		}
+		return suggestions
+	}
+
+	key := last[:keyEnd]
+	valuePrefix := prefix + key + delimiter
+	valuePartial := last[keyEnd+len(delimiter):]
+
+	var valueType reflect.Type
+	var value reflect.Value
+	if plan.kind == reflect.Struct {
+		field, exists := plan.fields[key]
+		if !exists {
+			return suggestions
+		}
+		valueType = field.plan.typ
+		if rv.IsValid() {
+			value, _ = rv.FieldByIndexErr(field.index)
+		}
+	} else {
+		valueType = plan.elem.typ
+		if rv.IsValid() && rv.Len() > 0 {
+			if parsedKey, err := d.Parse(key, plan.key.typ); err == nil {
+				value = rv.MapIndex(parsedKey)
+			}
+		}
+	}
+
+	for _, sub := range d.complete(valueType, value, valuePartial, completing) {
+		suggestions = append(suggestions, valuePrefix+sub)
+	}
+	return suggestions
+}
+
+// Returns the keys that can be suggested for a map or struct. Struct keys are
+// its decodable fields and map keys are the keys in the existing value.
+func (d *Decoder) completionKeys(plan *decodePlan, rv reflect.Value) []string {
+	keys := make([]string, 0)
+	if plan.kind == reflect.Struct {
+		for _, node := range GetTypeNodes(plan.concrete).InOrder {
+			if _, decodable := plan.fields[node.KeyString]; decodable {
+				keys = append(keys, node.KeyString)
+			}
+		}
+		return keys
+	}
+	if rv.IsValid() && rv.Kind() == reflect.Map {
+		for _, key := range rv.MapKeys() {
+			if encoded, err := d.encode(key); err == nil {
+				keys = append(keys, encoded)
+			}
+		}
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// Returns the text before the last top-level entry of the multi-valued
+// literal and the last entry itself. If the literal hasn't been started or
+// is already closed false is returned.
+func (d *Decoder) lastEntry(m Multi, s string) (string, string, bool) {
+	inner := s
+	if strings.HasPrefix(s, m.Start) {
+		inner = s[len(m.Start):]
+	} else if m.Strict || s == "" || strings.HasPrefix(m.Start, s) {
+		return "", "", false
+	}
+
+	starts, ends := d.delimiters()
+	depth := 0
+	quoted := false
+	lastStart := 0
+	for i := 0; i < len(inner); {
+		if inner[i] == '\'' {
+			quoted = !quoted
+			i++
+			continue
+		}
+		if quoted {
+			i++
+			continue
+		}
+		if depth == 0 && strings.HasPrefix(inner[i:], m.End) {
+			return "", "", false
+		}
+		if start := matchAny(inner[i:], starts); start > 0 {
+			depth++
+			i += start
+			continue
+		}
+		if depth > 0 {
+			if end := matchAny(inner[i:], ends); end > 0 {
+				depth--
+				i += end
+				continue
+			}
+			i++
+			continue
+		}
+		if loc := m.ValueSeparator.FindStringIndex(inner[i:]); loc != nil && loc[0] == 0 && loc[1] > 0 {
+			i += loc[1]
+			lastStart = i
+			continue
+		}
+		i++
+	}
+
+	last := inner[lastStart:]
+	return s[:len(s)-len(last)], last, true
+}
+
+// Returns the start and end delimiters of all multi-valued types, longest first.
+func (d *Decoder) delimiters() ([]string, []string) {
+	starts := []string{d.Slice.Start, d.Array.Start, d.Map.Start, d.Struct.Start}
+	ends := []string{d.Slice.End, d.Array.End, d.Map.End, d.Struct.End}
+	byLength := func(values []string) {
+		sort.Slice(values, func(i, j int) bool {
+			return len(values[i]) > len(values[j])
+		})
+	}
+	byLength(starts)
+	byLength(ends)
+	return starts, ends
+}
+
+// Returns the length of the first of the tokens that s starts with or 0.
+func matchAny(s string, tokens []string) int {
+	for _, token := range tokens {
+		if token != "" && strings.HasPrefix(s, token) {
+			return len(token)
+		}
+	}
+	return 0
+}
+
+// Suggests the start of the multi-valued literal if s could be its start.
+func appendStart(suggestions []string, m Multi, s string) []string {
+	if m.Start != "" && strings.HasPrefix(m.Start, s) {
+		suggestions = append(suggestions, m.Start)
+	}
+	return suggestions
+}
+
+// Adds prefix+candidate to the suggestions if the candidate starts with partial
+// (ignoring case) and hasn't already been suggested.
+func appendMatch(suggestions []string, prefix string, partial string, candidate string) []string {
+	if !strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) {
+		return suggestions
+	}
+	suggestion := prefix + candidate
+	for _, existing := range suggestions {
+		if existing == suggestion {
+			return suggestions
+		}
+	}
+	return append(suggestions, suggestion)
+}
@@ -0,0 +1,137 @@
+package refstr
+
+import (
+	"reflect"
+	"testing"
+)
+
+type completeServer struct {
+	Host    string
+	Enabled bool
+	Color   describeColor
+	Tags    map[string]struct{}
+	Limits  map[string]int
+	Ports   []int
+}
+
+type completeTree []completeTree
+
+type completeGraph map[string]completeGraph
+
+func TestComplete(t *testing.T) {
+	dec := NewDecoder()
+	dec.SetEnum(TypeOf[describeColor](), EnumValue{Name: "red", Value: 0}, EnumValue{Name: "green", Value: 1})
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		partial  string
+		expected []string
+	}{{
+		name:     "bool",
+		typ:      TypeOf[bool](),
+		partial:  "y",
+		expected: []string{"y", "ya", "yes"},
+	}, {
+		name:     "enum",
+		typ:      TypeOf[describeColor](),
+		partial:  "G",
+		expected: []string{"green"},
+	}, {
+		name:     "slice start",
+		typ:      TypeOf[[]bool](),
+		partial:  "",
+		expected: []string{"["},
+	}, {
+		name:     "slice element",
+		typ:      TypeOf[[]bool](),
+		partial:  "[true, fa",
+		expected: []string{"[true, false"},
+	}, {
+		name:     "slice end",
+		typ:      TypeOf[[]int](),
+		partial:  "[1 2 ",
+		expected: []string{"[1 2 ]"},
+	}, {
+		name:     "closed",
+		typ:      TypeOf[[]int](),
+		partial:  "[1 2]",
+		expected: []string{},
+	}, {
+		name:     "struct fields",
+		typ:      TypeOf[completeServer](),
+		partial:  "{",
+		expected: []string{"{}", "{Host:", "{Enabled:", "{Color:", "{Tags:", "{Limits:", "{Ports:"},
+	}, {
+		name:     "struct field prefix",
+		typ:      TypeOf[completeServer](),
+		partial:  "{Host:a E",
+		expected: []string{"{Host:a Enabled:"},
+	}, {
+		name:     "struct field value",
+		typ:      TypeOf[completeServer](),
+		partial:  "{Host:a Color:r",
+		expected: []string{"{Host:a Color:red"},
+	}, {
+		name:     "nested",
+		typ:      TypeOf[map[string][]describeColor](),
+		partial:  "map[a:[red] b:[red g",
+		expected: []string{"map[a:[red] b:[red green"},
+	}, {
+		name:     "nested struct close",
+		typ:      TypeOf[completeServer](),
+		partial:  "{Ports:[1 2] ",
+		expected: []string{"{Ports:[1 2] }", "{Ports:[1 2] Host:", "{Ports:[1 2] Enabled:", "{Ports:[1 2] Color:", "{Ports:[1 2] Tags:", "{Ports:[1 2] Limits:", "{Ports:[1 2] Ports:"},
+	}, {
+		name:     "set start",
+		typ:      TypeOf[map[describeColor]bool](),
+		partial:  "",
+		expected: []string{"["},
+	}, {
+		name:     "set",
+		typ:      TypeOf[map[describeColor]bool](),
+		partial:  "[gr",
+		expected: []string{"[green"},
+	}}
+
+	for _, test := range tests {
+		actual := dec.Complete(test.typ, test.partial)
+		if !StringEqual(actual, test.expected) {
+			t.Errorf("[%s] Expected %q but got %q", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestCompleteValue(t *testing.T) {
+	server := completeServer{Limits: map[string]int{"cpu": 1, "memory": 2, "disk": 3}}
+
+	actual := NewDecoder().CompleteValue(server, "{Limits:map[")
+	expected := []string{"{Limits:map[]", "{Limits:map[cpu:", "{Limits:map[disk:", "{Limits:map[memory:"}
+	if !StringEqual(actual, expected) {
+		t.Errorf("Expected %q but got %q", expected, actual)
+	}
+
+	actual = NewDecoder().CompleteValue(server, "{Limits:map[cpu:1 m")
+	expected = []string{"{Limits:map[cpu:1 memory:"}
+	if !StringEqual(actual, expected) {
+		t.Errorf("Expected %q but got %q", expected, actual)
+	}
+
+	if actual = NewDecoder().CompleteValue(nil, "{"); len(actual) != 0 {
+		t.Errorf("Expected no suggestions for nil but got %q", actual)
+	}
+}
+
+func TestCompleteRecursive(t *testing.T) {
+	dec := NewDecoder()
+	for _, partial := range []string{"x", "x y", "[x", "[[x] y"} {
+		dec.Complete(TypeOf[completeTree](), partial)
+		dec.Complete(TypeOf[completeGraph](), partial)
+	}
+
+	actual := dec.Complete(TypeOf[completeTree](), "[[] ")
+	expected := []string{"[[] ]", "[[] ["}
+	if !StringEqual(actual, expected) {
+		t.Errorf("Expected %q but got %q", expected, actual)
+	}
+}
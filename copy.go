@@ -0,0 +1,164 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+)
+
+// A value being copied has no field, key or index to copy it to.
+var ErrNoMatchingNode = errors.New("no matching node in the destination")
+
+// The result of copying a value: how many values were copied and the source
+// values which couldn't be copied and why.
+type CopyReport struct {
+	Copied   int
+	Unmapped []Match
+}
+
+// Returns the values which couldn't be copied as MatchErrors or nil if
+// everything was copied.
+func (r CopyReport) Err() error {
+	if len(r.Unmapped) == 0 {
+		return nil
+	}
+	return MatchErrors(r.Unmapped)
+}
+
+// Copies src into dst, which needs to be a pointer, with the default decoder.
+// See Decoder.Copy.
+func Copy(dst, src any) CopyReport {
+	return defaultDecoder.Load().Copy(dst, src)
+}
+
+// Copies src into dst, which needs to be a pointer. Fields, map keys and
+// slice or array elements are matched by name or key recursively, so values
+// of different types with the same shape can be copied, like []int32 into
+// []int or one struct into another struct or a map. Values of different types
+// are converted with this decoder. Slices in dst end up with the length of
+// the slice copied, maps and structs keep what wasn't copied over. The source
+// paths which couldn't be copied are in the report.
+func (d Decoder) Copy(dst, src any) CopyReport {
+	rv := Reflect(dst)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return d.copyTo(NewRef(src), NewRef(rv))
+}
+
+// Copies the referenced value into the destination with the default decoder.
+// See Decoder.Copy.
+func (r Ref) CopyTo(dst Ref) CopyReport {
+	return defaultDecoder.Load().copyTo(r, dst)
+}
+
+// Copies the referenced value into the destination with this decoder.
+func (d *Decoder) copyTo(src, dst Ref) CopyReport {
+	report := CopyReport{Unmapped: make([]Match, 0)}
+	value, err := src.Get()
+	if err != nil {
+		report.Unmapped = append(report.Unmapped, Match{Path: src.path, Err: err})
+		return report
+	}
+	c := &copier{decoder: d, report: &report, visiting: make(map[reference]bool)}
+	c.copy(src.path, value, dst)
+	return report
+}
+
+// The state of copying a value.
+type copier struct {
+	decoder  *Decoder
+	report   *CopyReport
+	visiting map[reference]bool
+}
+
+// Copies the value at the source path into the destination.
+func (c *copier) copy(path Path, src reflect.Value, dst Ref) {
+	cs := Concrete(src)
+	dt := dst.path.Type()
+	if !cs.IsValid() {
+		if dt != nil {
+			c.set(path, src, dst, reflect.Zero(dt))
+		}
+		return
+	}
+	if dt == nil || !isContainer(ConcreteType(dt)) || !isContainer(cs.Type()) || c.decoder.isLeaf(cs.Type()) {
+		c.set(path, src, dst, cs)
+		return
+	}
+	if isVisiting(src, c.visiting) {
+		return
+	}
+
+	visit(c.visiting, src, func() {
+		if cs.Kind() == reflect.Slice || cs.Kind() == reflect.Array {
+			if ConcreteType(dt).Kind() == reflect.Slice {
+				if err := dst.Set(InitType(dt)); err != nil {
+					c.unmapped(path, src, err)
+					return
+				}
+			}
+		}
+		for _, child := range childNodes(src) {
+			value := getNode(child, src)
+			next := c.next(dst, child)
+			if next == nil {
+				c.unmapped(path.append(child), value, ErrNoMatchingNode)
+				continue
+			}
+			c.copy(path.append(child), value, *next)
+		}
+	})
+}
+
+// Returns the destination node matching the source node. Keys are converted
+// to the key type of maps and slices in the destination.
+func (c *copier) next(dst Ref, child Node) *Ref {
+	nodes := dst.path.NextNodes()
+	if len(nodes.InOrder) == 1 && nodes.InOrder[0].IsDynamic() {
+		key := Reflect(child.Key)
+		if kt := nodes.InOrder[0].KeyType; kt != nil && !key.Type().AssignableTo(kt) {
+			converted, err := c.decoder.Convert(child.Key, kt)
+			if err != nil {
+				return nil
+			}
+			return dst.Next(converted)
+		}
+		return dst.Next(child.Key)
+	}
+	return dst.Next(child.KeyString)
+}
+
+// Sets the leaf value to the destination, converting it if its type is different.
+func (c *copier) set(path Path, src reflect.Value, dst Ref, value reflect.Value) {
+	if dt := dst.path.Type(); dt != nil && !value.Type().AssignableTo(dt) {
+		if !value.CanInterface() {
+			c.unmapped(path, src, ErrSetNotSupported)
+			return
+		}
+		converted, err := c.decoder.Convert(value.Interface(), dt)
+		if err != nil {
+			c.unmapped(path, src, err)
+			return
+		}
+		value = Reflect(converted)
+	}
+	if err := dst.Set(value); err != nil {
+		c.unmapped(path, src, err)
+		return
+	}
+	c.report.Copied++
+}
+
+// Adds a source value which couldn't be copied to the report.
+func (c *copier) unmapped(path Path, src reflect.Value, err error) {
+	c.report.Unmapped = append(c.report.Unmapped, Match{Path: path, Value: src, Err: err})
+}
+
+// Returns whether values of the concrete type have fields, keys or elements.
+func isContainer(rt reflect.Type) bool {
+	switch rt.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type copyAPIUser struct {
+	ID      string
+	Name    string
+	Age     int32
+	Scores  []int32
+	Address *copyAddress
+	Meta    map[string]string
+	Extra   string
+}
+
+type copyAddress struct {
+	City string
+	Zip  string
+}
+
+type copyDBUser struct {
+	ID      int64
+	Name    string
+	Age     int
+	Scores  []int
+	Address copyAddress
+	Meta    map[string]int
+	Created string
+}
+
+func TestCopy(t *testing.T) {
+	src := copyAPIUser{
+		ID:      "42",
+		Name:    "John",
+		Age:     30,
+		Scores:  []int32{1, 2},
+		Address: &copyAddress{City: "Paris", Zip: "75001"},
+		Meta:    map[string]string{"a": "1", "b": "x"},
+		Extra:   "e",
+	}
+	dst := copyDBUser{Scores: []int{9, 9, 9}, Created: "now"}
+
+	report := Copy(&dst, src)
+	expected := copyDBUser{
+		ID:      42,
+		Name:    "John",
+		Age:     30,
+		Scores:  []int{1, 2},
+		Address: copyAddress{City: "Paris", Zip: "75001"},
+		Meta:    map[string]int{"a": 1},
+		Created: "now",
+	}
+	if !reflect.DeepEqual(dst, expected) {
+		t.Errorf("expected %+v but got %+v", expected, dst)
+	}
+
+	unmapped := make([]string, len(report.Unmapped))
+	for i, m := range report.Unmapped {
+		unmapped[i] = m.Path.String()
+	}
+	if !StringEqual(unmapped, []string{"Meta[b]", "Extra"}) {
+		t.Errorf("unexpected unmapped %v", unmapped)
+	}
+	if !errors.Is(report.Err(), ErrNoMatchingNode) || report.Copied != 8 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestCopyToMap(t *testing.T) {
+	src := copyAddress{City: "Paris", Zip: "75001"}
+	dst := map[string]string{"Country": "France"}
+	if err := Copy(&dst, src).Err(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(dst, map[string]string{"Country": "France", "City": "Paris", "Zip": "75001"}) {
+		t.Errorf("unexpected copy %v", dst)
+	}
+}
+
+func TestCopyToSlicePointer(t *testing.T) {
+	src := copyAPIUser{Scores: []int32{1, 2}}
+	scores := []int{9, 9, 9}
+	dst := struct{ Scores *[]int }{Scores: &scores}
+	if err := Copy(&dst, src).Err(); err != nil && !errors.Is(err, ErrNoMatchingNode) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(*dst.Scores, []int{1, 2}) {
+		t.Errorf("expected the slice pointed to reset but got %v", *dst.Scores)
+	}
+}
+
+func TestRefCopyTo(t *testing.T) {
+	src := copyAPIUser{Scores: []int32{3, 4, 5}}
+	dst := copyDBUser{}
+	store := NewStore(&dst)
+	changes := 0
+	store.Subscribe("Scores", func(c Change) { changes++ })
+
+	report := NewRef(&src).Next("Scores").CopyTo(*store.Ref().Next("Scores"))
+	if err := report.Err(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(dst.Scores, []int{3, 4, 5}) || changes != 4 {
+		t.Errorf("expected scores copied through the store but got %v with %d changes", dst.Scores, changes)
+	}
+}
@@ -1,7 +1,6 @@
 package refstr
 
 import (
-	"encoding"
 	"errors"
 	"fmt"
 	"reflect"
@@ -12,22 +11,56 @@ import (
 
 var ErrDecodeInvalid = errors.New("error decoding into given value - it must be a pointer and a supported string type")
 
+// The values of an enum can't be converted to its type or its type can't be compared.
+var ErrInvalidEnum = errors.New("invalid enum")
+
 // A custom parser for a specified type.
 type Parser func(s string) (any, error)
 
+// A named value of an enum type.
+type EnumValue struct {
+	Name  string
+	Value any
+}
+
 // A decoder converts a string to a desired type.
+//
+// Locale controls how numbers and booleans are encoded, Bytes controls how
+// byte slices and arrays are read and written, and Ranges allows integer slice
+// elements to be written as ranges (1-5, 1..5 or 0-10/2) which can together
+// expand to at most MaxRange elements (0 for no limit). Chars allows rune
+// (int32) and byte (uint8) values to be written as character literals.
+// Enums are the named values of types and Hints describe the syntax custom
+// parsers accept.
+//
+// A decoder copied by assignment shares the Parsers, Enums, Hints, Trues and
+// Falses maps with the original, so writing to them directly changes both.
+// Clone and With make copies which share no maps, and SetParser, SetEnum and
+// SetHint replace the map they change instead of writing to it, so they
+// never change another decoder. Nothing stops the exported maps from being
+// written to directly, and a decoder must not be changed while it's in use
+// by other goroutines.
 type Decoder struct {
-	Slice   Multi
-	Array   Multi
-	Map     Multi
-	Struct  Multi
-	Parsers map[reflect.Type]Parser
-	Int     func(string, int) (int64, error)
-	Uint    func(string, int) (uint64, error)
-	Float   func(string, int) (float64, error)
-	Complex func(string, int) (complex128, error)
-	Trues   map[string]struct{}
-	Falses  map[string]struct{}
+	Slice    Multi
+	Array    Multi
+	Map      Multi
+	Struct   Multi
+	Parsers  map[reflect.Type]Parser
+	Int      func(string, int) (int64, error)
+	Uint     func(string, int) (uint64, error)
+	Float    func(string, int) (float64, error)
+	Complex  func(string, int) (complex128, error)
+	Trues    map[string]struct{}
+	Falses   map[string]struct{}
+	Locale   *Locale
+	Bytes    BytesEncoding
+	Ranges   bool
+	MaxRange int
+	Chars    CharMode
+	Enums    map[reflect.Type][]EnumValue
+	Hints    map[reflect.Type]string
+
+	plans *planCache
 }
 
 // A type for controlling the parsing of multi-value types.
@@ -37,6 +70,8 @@ type Multi struct {
 	KeySeparator   *regexp.Regexp
 	End            string
 	Strict         bool
+	ValueDelimiter string
+	KeyDelimiter   string
 }
 
 // Converts the given string to a slice of strings based on the Multi options.
@@ -66,16 +101,44 @@ func (m Multi) KeyValues(s string, max int) ([][2]string, error) {
 	return keyValues, nil
 }
 
-// Creates a new decoder with the default settings.
-func NewDecoder() Decoder {
+// Joins the given values into a single string based on the Multi options.
+// This is the inverse of Values.
+func (m Multi) Join(values []string) string {
+	delimiter := m.ValueDelimiter
+	if delimiter == "" {
+		delimiter = " "
+	}
+	return m.Start + strings.Join(values, delimiter) + m.End
+}
+
+// Joins the given key-value pairs into a single string based on the Multi
+// options. This is the inverse of KeyValues.
+func (m Multi) JoinKeyValues(keyValues [][2]string) string {
+	delimiter := m.KeyDelimiter
+	if delimiter == "" {
+		delimiter = ":"
+	}
+	entries := make([]string, len(keyValues))
+	for i, keyValue := range keyValues {
+		entries[i] = keyValue[0] + delimiter + keyValue[1]
+	}
+	return m.Join(entries)
+}
+
+// Creates a new decoder with the default settings and the given options
+// applied. The decoder owns all of its maps, to change its behavior later
+// derive a new decoder from it with With.
+func NewDecoder(opts ...DecoderOption) Decoder {
 	vs := regexp.MustCompile(`\s*[\s,|]+\s*`)
 
-	return Decoder{
-		Slice:   Multi{Start: "[", ValueSeparator: vs, End: "]"},
-		Array:   Multi{Start: "[", ValueSeparator: vs, End: "]"},
-		Map:     Multi{Start: "map[", ValueSeparator: vs, KeySeparator: regexp.MustCompile(`:`), End: "]"},
-		Struct:  Multi{Start: "{", ValueSeparator: vs, KeySeparator: regexp.MustCompile(`:`), End: "}"},
+	d := Decoder{
+		Slice:   Multi{Start: "[", ValueSeparator: vs, End: "]", ValueDelimiter: " "},
+		Array:   Multi{Start: "[", ValueSeparator: vs, End: "]", ValueDelimiter: " "},
+		Map:     Multi{Start: "map[", ValueSeparator: vs, KeySeparator: regexp.MustCompile(`:`), End: "]", ValueDelimiter: " ", KeyDelimiter: ":"},
+		Struct:  Multi{Start: "{", ValueSeparator: vs, KeySeparator: regexp.MustCompile(`:`), End: "}", ValueDelimiter: " ", KeyDelimiter: ":"},
 		Parsers: make(map[reflect.Type]Parser),
+		Enums:   make(map[reflect.Type][]EnumValue),
+		Hints:   make(map[reflect.Type]string),
 		Int:     func(s string, bits int) (int64, error) { return strconv.ParseInt(s, 10, bits) },
 		Uint:    func(s string, bits int) (uint64, error) { return strconv.ParseUint(s, 10, bits) },
 		Float:   func(s string, bits int) (float64, error) { return strconv.ParseFloat(s, bits) },
@@ -98,7 +161,47 @@ func NewDecoder() Decoder {
 			"0":     {},
 			"":      {},
 		},
+		MaxRange: 10000,
+		plans:    &planCache{},
+	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return d
+}
+
+// Returns a copy of this decoder which shares none of its maps or compiled
+// plans, so either can be changed without affecting the other.
+func (d Decoder) Clone() Decoder {
+	clone := d
+	clone.Parsers = make(map[reflect.Type]Parser, len(d.Parsers))
+	for t, p := range d.Parsers {
+		clone.Parsers[t] = p
+	}
+	clone.Enums = make(map[reflect.Type][]EnumValue, len(d.Enums))
+	for t, e := range d.Enums {
+		clone.Enums[t] = e
+	}
+	clone.Hints = make(map[reflect.Type]string, len(d.Hints))
+	for t, h := range d.Hints {
+		clone.Hints[t] = h
 	}
+	clone.Trues = cloneWords(d.Trues)
+	clone.Falses = cloneWords(d.Falses)
+	clone.plans = &planCache{}
+	return clone
+}
+
+// Returns a copy of the given set of words.
+func cloneWords(m map[string]struct{}) map[string]struct{} {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]struct{}, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
 }
 
 var kindBits map[reflect.Kind]int = map[reflect.Kind]int{
@@ -135,139 +238,69 @@ func (d Decoder) Decode(v any, s string) error {
 
 // Parses the string into the given type.
 func (d Decoder) Parse(s string, rt reflect.Type) (reflect.Value, error) {
-	val := InitType(rt)
-	concrete := Concrete(val)
+	val := reflect.New(rt).Elem()
+	err := d.plan(rt).decode(&d, s, val)
+	return val, err
+}
 
-	ptrMaybe := PointerMaybe(val).Interface()
-	if unmarshaller, ok := ptrMaybe.(encoding.TextUnmarshaler); ok {
-		err := unmarshaller.UnmarshalText([]byte(s))
-		if err != nil {
-			return val, fmt.Errorf("error unmarshalling text '%s': %w", s, err)
-		}
-		return val, nil
-	}
+// Registers a custom parser for the given type. The parsers are replaced by a
+// copy so other decoders which shared them are unaffected.
+func (d *Decoder) SetParser(rt reflect.Type, parser Parser) {
+	d.Parsers = withEntry(d.Parsers, rt, parser)
+}
 
-	if parser, exists := d.Parsers[concrete.Type()]; exists {
-		parsed, err := parser(s)
-		if err != nil {
-			return val, fmt.Errorf("error with custom parsing '%s': %w", s, err)
-		}
-		concrete.Set(reflect.ValueOf(parsed))
-		return val, nil
+// Registers the named values of a type so they can be decoded and encoded by
+// name. Values that aren't named are still decoded and encoded normally. If a
+// value can't be converted to the type an ErrInvalidEnum is returned and
+// nothing is registered. The enums are replaced by a copy so other decoders
+// which shared them are unaffected.
+func (d *Decoder) SetEnum(rt reflect.Type, values ...EnumValue) error {
+	if err := validateEnum(rt, values); err != nil {
+		return err
 	}
+	d.Enums = withEntry(d.Enums, rt, values)
+	return nil
+}
 
-	k := concrete.Kind()
-	switch k {
-	case reflect.Bool:
-		lower := strings.ToLower(s)
-		if _, isTrue := d.Trues[lower]; isTrue {
-			concrete.SetBool(true)
-			return val, nil
-		}
-		if _, isFalse := d.Falses[lower]; isFalse {
-			concrete.SetBool(false)
-			return val, nil
-		}
-		return val, fmt.Errorf("error parsing '%s' as bool", s)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		parsed, err := d.Int(s, kindBits[k])
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Kind(), err)
-		}
-		concrete.SetInt(parsed)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		parsed, err := d.Uint(s, kindBits[k])
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Kind(), err)
-		}
-		concrete.SetUint(parsed)
-	case reflect.Float32, reflect.Float64:
-		parsed, err := d.Float(s, kindBits[k])
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Kind(), err)
-		}
-		concrete.SetFloat(parsed)
-	case reflect.Complex64, reflect.Complex128:
-		parsed, err := d.Complex(s, kindBits[k])
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Kind(), err)
-		}
-		concrete.SetComplex(parsed)
-	case reflect.String:
-		concrete.SetString(s)
-	case reflect.Array:
-		elements, err := d.Array.Values(s, concrete.Len())
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Type(), err)
-		}
-		elementType := concrete.Type().Elem()
-		for i, elementString := range elements {
-			element, err := d.Parse(elementString, elementType)
-			if err != nil {
-				return val, fmt.Errorf("error parsing '%s' as %v: %w", elementString, elementType, err)
-			}
-			concrete.Index(i).Set(element)
-		}
-	case reflect.Slice:
-		if _, isBytes := concrete.Interface().([]byte); isBytes {
-			concrete.SetBytes([]byte(s))
-			return val, nil
-		}
-
-		elements, err := d.Slice.Values(s, -1)
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Type(), err)
-		}
-		elementType := concrete.Type().Elem()
-		for _, elementString := range elements {
-			element, err := d.Parse(elementString, elementType)
-			if err != nil {
-				return val, fmt.Errorf("error parsing '%s' as %v: %w", elementString, elementType, err)
-			}
-			concrete.Set(reflect.Append(concrete, element))
-		}
-	case reflect.Map:
-		keyValues, err := d.Map.KeyValues(s, -1)
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Type(), err)
+// Returns an ErrInvalidEnum if the type can't be compared or a value can't be
+// converted to the type.
+func validateEnum(rt reflect.Type, values []EnumValue) error {
+	if !rt.Comparable() {
+		return fmt.Errorf("%w: %v is not comparable", ErrInvalidEnum, rt)
+	}
+	for _, e := range values {
+		value := reflect.ValueOf(e.Value)
+		if !value.IsValid() || !value.Type().ConvertibleTo(rt) {
+			return fmt.Errorf("%w: %s value %v can't be converted to %v", ErrInvalidEnum, e.Name, e.Value, rt)
 		}
-		keyType := concrete.Type().Key()
-		valueType := concrete.Type().Elem()
+	}
+	return nil
+}
 
-		for _, keyValue := range keyValues {
-			key, err := d.Parse(keyValue[0], keyType)
-			if err != nil {
-				return val, fmt.Errorf("error parsing map key '%s' as %v: %w", keyValue[0], keyType, err)
-			}
-			value, err := d.Parse(keyValue[1], valueType)
-			if err != nil {
-				return val, fmt.Errorf("error parsing map value '%s' as %v: %w", keyValue[1], valueType, err)
-			}
-			concrete.SetMapIndex(key, value)
-		}
-	case reflect.Struct:
-		keyValues, err := d.Struct.KeyValues(s, -1)
-		if err != nil {
-			return val, fmt.Errorf("error parsing '%s' as %v: %w", s, concrete.Type(), err)
-		}
+// Registers a description of the syntax a custom parser accepts for a type
+// which is used when describing the type. The hints are replaced by a copy so
+// other decoders which shared them are unaffected.
+func (d *Decoder) SetHint(rt reflect.Type, hint string) {
+	d.Hints = withEntry(d.Hints, rt, hint)
+}
 
-		for _, keyValue := range keyValues {
-			fieldName := keyValue[0]
-			field := concrete.FieldByName(fieldName)
-			if !field.IsValid() {
-				return val, fmt.Errorf("error parsing '%s', unknown field '%s'", s, fieldName)
-			}
-			value, err := d.Parse(keyValue[1], field.Type())
-			if err != nil {
-				return val, fmt.Errorf("error parsing struct field '%s' with value '%s' as %v: %w", fieldName, keyValue[1], field.Type(), err)
-			}
-			field.Set(value)
-		}
-	default:
-		return val, fmt.Errorf("unsupported kind %v", concrete.Type())
+// Returns a copy of the map with the type set to the value.
+func withEntry[V any](m map[reflect.Type]V, key reflect.Type, value V) map[reflect.Type]V {
+	clone := make(map[reflect.Type]V, len(m)+1)
+	for k, v := range m {
+		clone[k] = v
 	}
+	clone[key] = value
+	return clone
+}
 
-	return val, nil
+// Discards the decoding plans compiled for this decoder and its copies. Plans
+// only depend on types, changes to Parsers and Enums apply without calling
+// this, so it only frees the memory the plans use.
+func (d Decoder) ResetPlans() {
+	if d.plans != nil {
+		d.plans.reset()
+	}
 }
 
 // Decodes a value of the given type from the given string and returns it.
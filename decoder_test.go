@@ -2,6 +2,7 @@ package refstr
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -85,3 +86,463 @@ func TestDecodeType(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeEmptyList(t *testing.T) {
+	for _, s := range []string{"[]", ""} {
+		var values []string
+		if err := Decode(&values, s); err != nil || !reflect.DeepEqual(values, []string{""}) {
+			t.Errorf("expected '%s' to decode into one empty string but got %q %v", s, values, err)
+		}
+	}
+}
+
+type planNode struct {
+	Value int
+	Next  *planNode
+}
+
+type planPoint struct{ X, Y float32 }
+
+func TestDecodePlans(t *testing.T) {
+	dec := NewDecoder()
+
+	var n planNode
+	if err := dec.Decode(&n, "{Value:4}"); err != nil || n.Value != 4 {
+		t.Fatalf("expected recursive type to decode, got %+v %v", n, err)
+	}
+
+	var p planPoint
+	if err := dec.Decode(&p, "{X:1 Y:2}"); err != nil || p.X != 1 {
+		t.Fatalf("expected point to decode, got %+v %v", p, err)
+	}
+
+	dec.SetParser(TypeOf[planPoint](), func(s string) (any, error) {
+		return planPoint{X: 9, Y: 9}, nil
+	})
+	if err := dec.Decode(&p, "anything"); err != nil || p.X != 9 {
+		t.Fatalf("expected new parser to be used, got %+v %v", p, err)
+	}
+
+	var ps []planPoint
+	if err := dec.Decode(&ps, "[a b]"); err != nil || !StringEqual(ps, []planPoint{{9, 9}, {9, 9}}) {
+		t.Fatalf("expected new parser to be used for elements, got %+v %v", ps, err)
+	}
+
+	dec.SetParser(TypeOf[planPoint](), func(s string) (any, error) {
+		return planPoint{X: 7}, nil
+	})
+	if err := dec.Decode(&p, "anything"); err != nil || p.X != 7 {
+		t.Fatalf("expected replaced parser to be used, got %+v %v", p, err)
+	}
+
+	copied := dec
+	copied.Parsers = map[reflect.Type]Parser{}
+	for i := 0; i < 2; i++ {
+		if err := copied.Decode(&p, "{X:1}"); err != nil || p.X != 1 {
+			t.Fatalf("expected copy without parsers to decode fields, got %+v %v", p, err)
+		}
+		if err := dec.Decode(&p, "{X:1}"); err != nil || p.X != 7 {
+			t.Fatalf("expected original to keep its parser, got %+v %v", p, err)
+		}
+	}
+
+	copied.Parsers[TypeOf[planPoint]()] = func(s string) (any, error) {
+		return planPoint{X: 5}, nil
+	}
+	if err := copied.Decode(&p, "{X:1}"); err != nil || p.X != 5 {
+		t.Fatalf("expected a parser written to the map to be used, got %+v %v", p, err)
+	}
+	copied.Enums[TypeOf[int]()] = []EnumValue{{Name: "one", Value: 1}}
+	var n1 int
+	if err := copied.Decode(&n1, "one"); err != nil || n1 != 1 {
+		t.Fatalf("expected an enum written to the map to be used, got %v %v", n1, err)
+	}
+}
+
+func BenchmarkParseScalar(b *testing.B) {
+	dec := NewDecoder()
+	rt := TypeOf[int]()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.Parse("1234", rt)
+	}
+}
+
+func BenchmarkParseSlice(b *testing.B) {
+	dec := NewDecoder()
+	rt := TypeOf[[]float32]()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.Parse("[0.5 1 3.1415 4 5]", rt)
+	}
+}
+
+func BenchmarkParseStruct(b *testing.B) {
+	dec := NewDecoder()
+	rt := TypeOf[planPoint]()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.Parse("{X:2 Y:5.4}", rt)
+	}
+}
+
+func TestDecoderClone(t *testing.T) {
+	dec := NewDecoder()
+	clone := dec.Clone()
+	clone.Trues["yeppers"] = struct{}{}
+
+	if _, err := dec.DecodeType(TypeOf[bool](), "yeppers"); err == nil {
+		t.Errorf("expected original decoder to be unaffected by clone")
+	}
+	if v, err := clone.DecodeType(TypeOf[bool](), "yeppers"); err != nil || v != true {
+		t.Errorf("expected clone to decode new true value, got %v %v", v, err)
+	}
+}
+
+func TestDecoderConcurrent(t *testing.T) {
+	type inner struct{ A []int }
+	type outer struct {
+		B map[string]inner
+		C *float64
+	}
+
+	dec := NewDecoder()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			local := dec.Clone()
+			for k := 0; k < 100; k++ {
+				var o outer
+				if err := local.Decode(&o, "{C:4.5}"); err != nil || *o.C != 4.5 {
+					t.Errorf("unexpected decode %+v %v", o, err)
+				}
+				var b bool
+				if err := Decode(&b, "yes"); err != nil || !b {
+					t.Errorf("unexpected decode %v %v", b, err)
+				}
+				if k%10 == 0 {
+					local.SetParser(TypeOf[inner](), func(s string) (any, error) {
+						return inner{A: []int{i}}, nil
+					})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDecodeSets(t *testing.T) {
+	type flag bool
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		decode   string
+		expected any
+		encoded  string
+	}{{
+		name:     "struct set list",
+		typ:      TypeOf[map[string]struct{}](),
+		decode:   "[a b c]",
+		expected: map[string]struct{}{"a": {}, "b": {}, "c": {}},
+		encoded:  "[a b c]",
+	}, {
+		name:     "struct set map syntax",
+		typ:      TypeOf[map[string]struct{}](),
+		decode:   "map[a: b:]",
+		expected: map[string]struct{}{"a": {}, "b": {}},
+		encoded:  "[a b]",
+	}, {
+		name:     "bool set list",
+		typ:      TypeOf[map[int]bool](),
+		decode:   "[3, 1, 2]",
+		expected: map[int]bool{1: true, 2: true, 3: true},
+		encoded:  "[1 2 3]",
+	}, {
+		name:     "struct set list with key separator",
+		typ:      TypeOf[map[string]struct{}](),
+		decode:   "[localhost:8080 b]",
+		expected: map[string]struct{}{"localhost:8080": {}, "b": {}},
+		encoded:  "[b localhost:8080]",
+	}, {
+		name:     "bool set mixed",
+		typ:      TypeOf[map[string]bool](),
+		decode:   "map[a b:false c:true]",
+		expected: map[string]bool{"a": true, "b": false, "c": true},
+		encoded:  "[a c]",
+	}, {
+		name:     "named bool set",
+		typ:      TypeOf[map[string]flag](),
+		decode:   "[x]",
+		expected: map[string]flag{"x": true},
+		encoded:  "[x]",
+	}, {
+		name:     "empty set",
+		typ:      TypeOf[map[string]struct{}](),
+		decode:   "[]",
+		expected: map[string]struct{}{},
+		encoded:  "[]",
+	}}
+
+	for _, test := range tests {
+		val, err := DecodeType(test.typ, test.decode)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error during DecodeType: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(val, test.expected) {
+			t.Errorf("[%s] Expected %+v but got %+v", test.name, test.expected, val)
+		}
+		encoded, err := Encode(val)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("[%s] Expected encoded %s but got %s (%v)", test.name, test.encoded, encoded, err)
+		}
+	}
+}
+
+func TestDecodeRanges(t *testing.T) {
+	dec := NewDecoder()
+	dec.Ranges = true
+	dec.MaxRange = 100
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		decode   string
+		expected any
+		encoded  string
+		err      bool
+	}{{
+		name:     "ports",
+		typ:      TypeOf[[]int](),
+		decode:   "[8000-8003 9000]",
+		expected: []int{8000, 8001, 8002, 8003, 9000},
+		encoded:  "[8000-8003 9000]",
+	}, {
+		name:     "dots",
+		typ:      TypeOf[[]uint16](),
+		decode:   "[1..3, 5 6]",
+		expected: []uint16{1, 2, 3, 5, 6},
+		encoded:  "[1-3 5 6]",
+	}, {
+		name:     "uintptr",
+		typ:      TypeOf[[]uintptr](),
+		decode:   "[1-4]",
+		expected: []uintptr{1, 2, 3, 4},
+		encoded:  "[1-4]",
+	}, {
+		name:     "step",
+		typ:      TypeOf[[]int8](),
+		decode:   "[0-10/4]",
+		expected: []int8{0, 4, 8},
+		encoded:  "[0 4 8]",
+	}, {
+		name:     "descending",
+		typ:      TypeOf[[]int](),
+		decode:   "[3-1]",
+		expected: []int{3, 2, 1},
+		encoded:  "[3 2 1]",
+	}, {
+		name:     "negative",
+		typ:      TypeOf[[]int](),
+		decode:   "[-3..-1 -7]",
+		expected: []int{-3, -2, -1, -7},
+		encoded:  "[-3..-1 -7]",
+	}, {
+		name:   "too many",
+		typ:    TypeOf[[]int](),
+		decode: "[1-50 100-150]",
+		err:    true,
+	}, {
+		name:   "overflow",
+		typ:    TypeOf[[]uint16](),
+		decode: "[65530-65540]",
+		err:    true,
+	}, {
+		name:   "negative unsigned",
+		typ:    TypeOf[[]uint](),
+		decode: "[-1..1]",
+		err:    true,
+	}, {
+		name:   "zero step",
+		typ:    TypeOf[[]int](),
+		decode: "[1-5/0]",
+		err:    true,
+	}}
+
+	for _, test := range tests {
+		val, err := dec.DecodeType(test.typ, test.decode)
+		if (err != nil) != test.err {
+			t.Errorf("[%s] Unexpected error result during DecodeType: %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if !reflect.DeepEqual(val, test.expected) {
+			t.Errorf("[%s] Expected %+v but got %+v", test.name, test.expected, val)
+		}
+		encoded, err := dec.Encode(val)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("[%s] Expected encoded %s but got %s (%v)", test.name, test.encoded, encoded, err)
+		}
+	}
+
+	plain := make([]int, 150)
+	for i := range plain {
+		plain[i] = i * 2
+	}
+	encoded, _ := dec.Encode(plain)
+	if val, err := dec.DecodeType(TypeOf[[]int](), encoded); err != nil || !reflect.DeepEqual(val, plain) {
+		t.Errorf("expected plain elements to not count towards the maximum but got %v", err)
+	}
+
+	if _, err := DecodeType(TypeOf[[]int](), "[1-3]"); err == nil {
+		t.Errorf("expected ranges to be disabled by default")
+	}
+}
+
+func TestDecodeChars(t *testing.T) {
+	quoted := NewDecoder()
+	quoted.Chars = CharsQuoted
+	bare := NewDecoder()
+	bare.Chars = CharsBare
+
+	tests := []struct {
+		name     string
+		decoder  Decoder
+		typ      reflect.Type
+		decode   string
+		expected any
+		encoded  string
+		err      bool
+	}{{
+		name:     "rune",
+		decoder:  quoted,
+		typ:      TypeOf[rune](),
+		decode:   "'a'",
+		expected: 'a',
+		encoded:  "'a'",
+	}, {
+		name:     "rune escape",
+		decoder:  quoted,
+		typ:      TypeOf[rune](),
+		decode:   `'\n'`,
+		expected: '\n',
+		encoded:  `'\n'`,
+	}, {
+		name:     "rune unicode",
+		decoder:  quoted,
+		typ:      TypeOf[rune](),
+		decode:   "'é'",
+		expected: 'é',
+		encoded:  "'é'",
+	}, {
+		name:     "rune number",
+		decoder:  quoted,
+		typ:      TypeOf[rune](),
+		decode:   "97",
+		expected: 'a',
+		encoded:  "'a'",
+	}, {
+		name:     "byte",
+		decoder:  quoted,
+		typ:      TypeOf[byte](),
+		decode:   `'\x7f'`,
+		expected: byte(127),
+		encoded:  `'\x7f'`,
+	}, {
+		name:    "byte too big",
+		decoder: quoted,
+		typ:     TypeOf[byte](),
+		decode:  "'世'",
+		err:     true,
+	}, {
+		name:    "byte not ascii",
+		decoder: quoted,
+		typ:     TypeOf[byte](),
+		decode:  "'é'",
+		err:     true,
+	}, {
+		name:     "byte not ascii number",
+		decoder:  quoted,
+		typ:      TypeOf[byte](),
+		decode:   "233",
+		expected: byte(233),
+		encoded:  "233",
+	}, {
+		name:    "multiple characters",
+		decoder: quoted,
+		typ:     TypeOf[rune](),
+		decode:  "'ab'",
+		err:     true,
+	}, {
+		name:    "bare disabled",
+		decoder: quoted,
+		typ:     TypeOf[rune](),
+		decode:  "a",
+		err:     true,
+	}, {
+		name:     "bare",
+		decoder:  bare,
+		typ:      TypeOf[rune](),
+		decode:   "a",
+		expected: 'a',
+		encoded:  "'a'",
+	}, {
+		name:     "bare digit",
+		decoder:  bare,
+		typ:      TypeOf[byte](),
+		decode:   "7",
+		expected: byte(7),
+		encoded:  `'\a'`,
+	}, {
+		name:     "rune slice",
+		decoder:  quoted,
+		typ:      TypeOf[[]rune](),
+		decode:   "['a' 'b']",
+		expected: []rune{'a', 'b'},
+		encoded:  "['a' 'b']",
+	}, {
+		name:     "rune slice separators",
+		decoder:  quoted,
+		typ:      TypeOf[[]rune](),
+		decode:   "['a' 32 44]",
+		expected: []rune{'a', ' ', ','},
+		encoded:  "['a' 32 44]",
+	}, {
+		name:     "byte array",
+		decoder:  bare,
+		typ:      TypeOf[[2]byte](),
+		decode:   "[x 'y']",
+		expected: [2]byte{'x', 'y'},
+		encoded:  "['x' 'y']",
+	}, {
+		name:     "rune keys",
+		decoder:  quoted,
+		typ:      TypeOf[map[rune]int](),
+		decode:   "map['a':1 'b':2]",
+		expected: map[rune]int{'a': 1, 'b': 2},
+		encoded:  "map['a':1 'b':2]",
+	}}
+
+	for _, test := range tests {
+		val, err := test.decoder.DecodeType(test.typ, test.decode)
+		if (err != nil) != test.err {
+			t.Errorf("[%s] Unexpected error result during DecodeType: %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if !reflect.DeepEqual(val, test.expected) {
+			t.Errorf("[%s] Expected %+v but got %+v", test.name, test.expected, val)
+		}
+		encoded, err := test.decoder.Encode(val)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("[%s] Expected encoded %s but got %s (%v)", test.name, test.encoded, encoded, err)
+		}
+	}
+}
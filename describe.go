@@ -0,0 +1,314 @@
+package refstr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A description of the strings a decoder accepts for a type.
+type Schema struct {
+	// The type described.
+	Type reflect.Type
+	// The concrete kind of the type.
+	Kind reflect.Kind
+	// A short summary of the accepted syntax.
+	Syntax string
+	// The start, end and separators of multi-valued types.
+	Start        string
+	End          string
+	Separator    string
+	KeySeparator string
+	// The words accepted for booleans.
+	Trues  []string
+	Falses []string
+	// The names accepted for enum types.
+	Enum []string
+	// The hint registered for types with custom parsers.
+	Hint string
+	// The schemas of map keys, map values & slice or array elements.
+	Key  *Schema
+	Elem *Schema
+	// The schemas of the fields of a struct.
+	Fields []SchemaField
+	// An example string that can be decoded into the type.
+	Example string
+	// Whether the type is described further up in the schema.
+	Recursive bool
+}
+
+// A field of a struct schema.
+type SchemaField struct {
+	Name   string
+	Schema *Schema
+}
+
+// Returns a description of the strings this decoder accepts for the given type.
+func (d Decoder) Schema(rt reflect.Type) *Schema {
+	return d.schema(rt, make(map[reflect.Type]bool))
+}
+
+// Returns a human readable description of the strings this decoder accepts
+// for the given type.
+func (d Decoder) Describe(rt reflect.Type) string {
+	return d.Schema(rt).String()
+}
+
+// Builds the schema for a type, types being visited are recursive.
+func (d *Decoder) schema(rt reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	c := ConcreteType(rt)
+	s := &Schema{Type: rt, Kind: c.Kind()}
+	if visiting[c] {
+		s.Recursive = true
+		s.Syntax = fmt.Sprintf("%v as described above", c)
+		return s
+	}
+	visiting[c] = true
+	defer delete(visiting, c)
+
+	plan := d.plan(rt)
+	s.Hint = d.Hints[c]
+	for _, e := range plan.enum(d) {
+		s.Enum = append(s.Enum, e.Name)
+	}
+
+	switch {
+	case plan.unmarshal:
+		s.Syntax = fmt.Sprintf("text accepted by %v", c)
+		if marshaller, ok := textMarshaler(reflect.New(c).Elem()); ok {
+			if text, err := marshaller.MarshalText(); err == nil {
+				s.Example = string(text)
+			}
+		}
+	case plan.parser(d) != nil:
+		s.Syntax = "custom format"
+	default:
+		d.describeKind(s, c, visiting)
+	}
+
+	if s.Hint != "" && plan.parser(d) != nil {
+		s.Syntax = s.Hint
+	}
+	if len(s.Enum) > 0 {
+		s.Syntax = fmt.Sprintf("one of %s or %s", strings.Join(s.Enum, ", "), s.Syntax)
+		s.Example = s.Enum[0]
+	}
+
+	return s
+}
+
+// Describes the syntax for a type without a custom parser.
+func (d *Decoder) describeKind(s *Schema, c reflect.Type, visiting map[reflect.Type]bool) {
+	switch c.Kind() {
+	case reflect.Bool:
+		s.Syntax = "boolean"
+		s.Trues = d.boolWords(true)
+		s.Falses = d.boolWords(false)
+		s.Example = d.example(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Syntax = "integer"
+		s.Example = d.example(reflect.ValueOf(42).Convert(c))
+		if c.Kind() == reflect.Int32 && d.Chars != CharsOff {
+			s.Syntax = "integer or character literal"
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Syntax = "non-negative integer"
+		s.Example = d.example(reflect.ValueOf(42).Convert(c))
+		if c.Kind() == reflect.Uint8 && d.Chars != CharsOff {
+			s.Syntax = "non-negative integer or character literal"
+		}
+	case reflect.Float32, reflect.Float64:
+		s.Syntax = "decimal number"
+		if d.Locale != nil && d.Locale.Percent {
+			s.Syntax = "decimal number or percentage"
+		}
+		s.Example = d.example(reflect.ValueOf(1234.5).Convert(c))
+	case reflect.Complex64, reflect.Complex128:
+		s.Syntax = "complex number"
+		s.Example = d.example(reflect.ValueOf(1 + 2i).Convert(c))
+	case reflect.String:
+		s.Syntax = "text"
+		s.Example = "text"
+	case reflect.Slice:
+		if isBytes(c) {
+			s.Syntax = d.bytesSyntax()
+			s.Example = d.encodeBytes([]byte("bytes"))
+			return
+		}
+		s.Elem = d.schema(c.Elem(), visiting)
+		s.Syntax = fmt.Sprintf("list of %s", s.Elem.Type)
+		if d.Ranges && isIntegerKind(c.Elem().Kind()) {
+			s.Syntax += " or ranges like 1-5, 1..5 and 0-10/2"
+		}
+		d.describeMulti(s, d.Slice, false)
+		s.Example = d.Slice.Join(examples(s.Elem, 2))
+	case reflect.Array:
+		if c.Elem() == byteType && d.Bytes != BytesRaw {
+			s.Syntax = fmt.Sprintf("%d %s", c.Len(), d.bytesSyntax())
+			s.Example = d.encodeBytes(make([]byte, c.Len()))
+			return
+		}
+		s.Elem = d.schema(c.Elem(), visiting)
+		s.Syntax = fmt.Sprintf("list of up to %d %s", c.Len(), s.Elem.Type)
+		d.describeMulti(s, d.Array, false)
+		n := c.Len()
+		if n > 2 {
+			n = 2
+		}
+		s.Example = d.Array.Join(examples(s.Elem, n))
+	case reflect.Map:
+		s.Key = d.schema(c.Key(), visiting)
+		if isSetMap(c) {
+			s.Syntax = fmt.Sprintf("set of %s", s.Key.Type)
+			d.describeMulti(s, d.Slice, false)
+			s.Example = d.Slice.Join(examples(s.Key, 1))
+			return
+		}
+		s.Elem = d.schema(c.Elem(), visiting)
+		s.Syntax = fmt.Sprintf("map of %s to %s", s.Key.Type, s.Elem.Type)
+		d.describeMulti(s, d.Map, true)
+		s.Example = d.Map.JoinKeyValues([][2]string{{s.Key.Example, s.Elem.Example}})
+	case reflect.Struct:
+		plan := d.plan(c)
+		names := make([]string, 0, len(plan.fields))
+		keyValues := make([][2]string, 0, len(plan.fields))
+		for _, field := range encodedFields(c) {
+			if _, decoded := plan.fields[field.Name]; !decoded {
+				continue
+			}
+			fieldSchema := d.schema(field.Type, visiting)
+			s.Fields = append(s.Fields, SchemaField{Name: field.Name, Schema: fieldSchema})
+			names = append(names, field.Name)
+			if !fieldSchema.Recursive && fieldSchema.Example != "" {
+				keyValues = append(keyValues, [2]string{field.Name, fieldSchema.Example})
+			}
+		}
+		s.Syntax = fmt.Sprintf("struct with fields %s", strings.Join(names, ", "))
+		d.describeMulti(s, d.Struct, true)
+		s.Example = d.Struct.JoinKeyValues(keyValues)
+	default:
+		s.Syntax = "unsupported"
+	}
+}
+
+// Sets the delimiters of the multi-valued schema.
+func (d *Decoder) describeMulti(s *Schema, m Multi, keyed bool) {
+	s.Start = m.Start
+	s.End = m.End
+	if m.ValueSeparator != nil {
+		s.Separator = m.ValueSeparator.String()
+	}
+	if keyed && m.KeySeparator != nil {
+		s.KeySeparator = m.KeySeparator.String()
+	}
+}
+
+// Describes the syntax of byte slices.
+func (d *Decoder) bytesSyntax() string {
+	switch d.Bytes {
+	case BytesHex:
+		return "hexadecimal bytes"
+	case BytesBase64, BytesBase64Raw:
+		return "base64 bytes"
+	case BytesBase64URL, BytesBase64RawURL:
+		return "url safe base64 bytes"
+	case BytesAuto:
+		return fmt.Sprintf("bytes as text or prefixed with %s, %s or %s", BytesHexPrefix, BytesBase64Prefix, BytesBase64URLPrefix)
+	}
+	return "text"
+}
+
+// Returns the accepted boolean words, the one used when encoding first.
+func (d *Decoder) boolWords(value bool) []string {
+	set := d.Falses
+	if value {
+		set = d.Trues
+	}
+	preferred := d.example(value)
+	words := make([]string, 0, len(set))
+	for word := range set {
+		if word != preferred {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	if _, accepted := set[preferred]; accepted {
+		words = append([]string{preferred}, words...)
+	}
+	return words
+}
+
+// Encodes the example value, returning an empty string if it can't be.
+func (d *Decoder) example(v any) string {
+	example, err := d.encode(Reflect(v))
+	if err != nil {
+		return ""
+	}
+	return example
+}
+
+// Returns the example of the schema repeated n times.
+func examples(s *Schema, n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = s.Example
+	}
+	return values
+}
+
+// Returns a human readable description of the schema.
+func (s *Schema) String() string {
+	sb := &strings.Builder{}
+	s.write(sb, "", "")
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Writes the schema with the given indentation and label.
+func (s *Schema) write(sb *strings.Builder, indent string, label string) {
+	fmt.Fprintf(sb, "%s%s%v: %s\n", indent, label, s.Type, s.Syntax)
+	if s.Recursive {
+		return
+	}
+	inner := indent + "  "
+	if s.Start != "" || s.End != "" || s.Separator != "" {
+		if s.KeySeparator != "" {
+			fmt.Fprintf(sb, "%sformat: %skey:value ...%s (values separated by /%s/, keys by /%s/)\n", inner, s.Start, s.End, s.Separator, s.KeySeparator)
+		} else {
+			fmt.Fprintf(sb, "%sformat: %svalue ...%s (values separated by /%s/)\n", inner, s.Start, s.End, s.Separator)
+		}
+	}
+	if len(s.Trues) > 0 {
+		fmt.Fprintf(sb, "%strue: %s\n", inner, strings.Join(quoteWords(s.Trues), ", "))
+	}
+	if len(s.Falses) > 0 {
+		fmt.Fprintf(sb, "%sfalse: %s\n", inner, strings.Join(quoteWords(s.Falses), ", "))
+	}
+	if s.Hint != "" && s.Hint != s.Syntax {
+		fmt.Fprintf(sb, "%shint: %s\n", inner, s.Hint)
+	}
+	if s.Example != "" {
+		fmt.Fprintf(sb, "%sexample: %s\n", inner, s.Example)
+	}
+	if s.Key != nil {
+		s.Key.write(sb, inner, "key ")
+	}
+	if s.Elem != nil {
+		s.Elem.write(sb, inner, "element ")
+	}
+	for _, field := range s.Fields {
+		field.Schema.write(sb, inner, field.Name+" ")
+	}
+}
+
+// Quotes the empty word so it is visible in descriptions.
+func quoteWords(words []string) []string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		if word == "" {
+			word = `""`
+		}
+		quoted[i] = word
+	}
+	return quoted
+}
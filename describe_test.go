@@ -0,0 +1,131 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type describeColor int
+
+type describeNode struct {
+	Name   string
+	Color  describeColor
+	Next   *describeNode
+	Scores []float64
+}
+
+func TestDescribe(t *testing.T) {
+	dec := NewDecoder()
+	dec.SetEnum(TypeOf[describeColor](), EnumValue{Name: "red", Value: 0}, EnumValue{Name: "green", Value: 1})
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		syntax   string
+		example  string
+		contains []string
+	}{{
+		name:     "bool",
+		typ:      TypeOf[bool](),
+		syntax:   "boolean",
+		example:  "true",
+		contains: []string{"true: true, 1, si", `false: false, "", 0`},
+	}, {
+		name:    "*int",
+		typ:     TypeOf[*int](),
+		syntax:  "integer",
+		example: "42",
+	}, {
+		name:    "enum",
+		typ:     TypeOf[describeColor](),
+		syntax:  "one of red, green or integer",
+		example: "red",
+	}, {
+		name:     "slice",
+		typ:      TypeOf[[]float32](),
+		syntax:   "list of float32",
+		example:  "[1234.5 1234.5]",
+		contains: []string{"format: [value ...]", "element float32: decimal number"},
+	}, {
+		name:    "array",
+		typ:     TypeOf[[3]bool](),
+		syntax:  "list of up to 3 bool",
+		example: "[true true]",
+	}, {
+		name:     "map",
+		typ:      TypeOf[map[string]int](),
+		syntax:   "map of string to int",
+		example:  "map[text:42]",
+		contains: []string{"format: map[key:value ...]", "key string: text", "element int: integer"},
+	}, {
+		name:    "set",
+		typ:     TypeOf[map[int]struct{}](),
+		syntax:  "set of int",
+		example: "[42]",
+	}, {
+		name:     "recursive struct",
+		typ:      TypeOf[describeNode](),
+		syntax:   "struct with fields Name, Color, Next, Scores",
+		example:  "{Name:text Color:red Scores:[1234.5 1234.5]}",
+		contains: []string{"Next *refstr.describeNode: refstr.describeNode as described above"},
+	}}
+
+	for _, test := range tests {
+		schema := dec.Schema(test.typ)
+		if schema.Syntax != test.syntax {
+			t.Errorf("[%s] Expected syntax %q but got %q", test.name, test.syntax, schema.Syntax)
+		}
+		if schema.Example != test.example {
+			t.Errorf("[%s] Expected example %q but got %q", test.name, test.example, schema.Example)
+		}
+		description := dec.Describe(test.typ)
+		for _, c := range test.contains {
+			if !strings.Contains(description, c) {
+				t.Errorf("[%s] Expected description to contain %q but got:\n%s", test.name, c, description)
+			}
+		}
+	}
+}
+
+func TestDescribeHint(t *testing.T) {
+	type version struct{ Major, Minor int }
+
+	dec := NewDecoder()
+	dec.SetParser(TypeOf[version](), func(s string) (any, error) { return version{}, nil })
+	dec.SetHint(TypeOf[version](), "major.minor version like 1.2")
+
+	if syntax := dec.Schema(TypeOf[version]()).Syntax; syntax != "major.minor version like 1.2" {
+		t.Errorf("expected hint as syntax but got %q", syntax)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	dec := NewDecoder()
+	dec.SetEnum(TypeOf[describeColor](), EnumValue{Name: "red", Value: 0}, EnumValue{Name: "green", Value: 1})
+
+	val, err := dec.DecodeType(TypeOf[[]describeColor](), "[GREEN red 5]")
+	if err != nil || !reflect.DeepEqual(val, []describeColor{1, 0, 5}) {
+		t.Fatalf("unexpected enum decode %v %v", val, err)
+	}
+	encoded, err := dec.Encode(val)
+	if err != nil || encoded != "[green red 5]" {
+		t.Fatalf("unexpected enum encode %v %v", encoded, err)
+	}
+
+	if err := dec.SetEnum(TypeOf[describeColor](), EnumValue{Name: "blue", Value: "x"}); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected an invalid enum value but got %v", err)
+	}
+	if err := dec.SetEnum(TypeOf[[]int](), EnumValue{Name: "none", Value: []int{}}); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected an uncomparable enum type but got %v", err)
+	}
+
+	invalid := NewDecoder(WithEnum(TypeOf[describeColor](), EnumValue{Name: "blue", Value: "x"}))
+	if _, err := invalid.DecodeType(TypeOf[describeColor](), "blue"); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected decoding an invalid enum to fail but got %v", err)
+	}
+	if _, err := invalid.Encode(describeColor(1)); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected encoding an invalid enum to fail but got %v", err)
+	}
+}
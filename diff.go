@@ -0,0 +1,219 @@
+package refstr
+
+import (
+	"reflect"
+)
+
+// An option which changes how two values are compared by Diff.
+type DiffOption func(*differ)
+
+// Matches the elements of the slices or arrays at the path by the value at
+// the key sub-path of each element instead of by index, like
+// DiffKeyedBy("Servers", "Name"). The path can have wildcards. Matched
+// elements which moved have the path of their index in the new value and
+// removed elements have the path of their index in the old value. Paths
+// which can't be parsed are ignored.
+func DiffKeyedBy(path string, key string) DiffOption {
+	return func(d *differ) {
+		d.keyed = append(d.keyed, diffKey{path: path, key: key})
+	}
+}
+
+// Compares the values returned by getter methods as well as fields.
+func DiffMethods() DiffOption {
+	return func(d *differ) {
+		d.methods = true
+	}
+}
+
+// Compares two values of the same type and returns the changes which turn a
+// into b. Fields, map keys and slice or array elements only in b are added,
+// the ones only in a are removed and leaves with different values are
+// modified. Map entries are matched by key and slices by index unless keyed
+// by DiffKeyedBy. Unexported fields and getter methods are not compared
+// unless DiffMethods is given.
+func Diff(a, b any, options ...DiffOption) []Change {
+	ra, rb := Reflect(a), Reflect(b)
+	d := &differ{changes: make([]Change, 0), visiting: make(map[reference]bool)}
+	if !ra.IsValid() && !rb.IsValid() {
+		return d.changes
+	}
+	rt := ra.Type()
+	if !ra.IsValid() {
+		rt = rb.Type()
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+	keyed := d.keyed[:0]
+	for _, key := range d.keyed {
+		if slices, err := ParsePath(rt, key.path); err == nil {
+			key.slices = slices
+			keyed = append(keyed, key)
+		}
+	}
+	d.keyed = keyed
+
+	d.diff(NewPath(rt), ra, rb)
+	return d.changes
+}
+
+// The state of comparing two values.
+type differ struct {
+	keyed    []diffKey
+	methods  bool
+	changes  []Change
+	visiting map[reference]bool
+}
+
+// Slices matched by a key sub-path.
+type diffKey struct {
+	path   string
+	key    string
+	slices Path
+}
+
+// Compares the values at the path.
+func (d *differ) diff(path Path, a, b reflect.Value) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		d.changes = append(d.changes, Change{Kind: ChangeAdded, Path: path, New: b})
+		return
+	case !b.IsValid():
+		d.changes = append(d.changes, Change{Kind: ChangeRemoved, Path: path, Old: a})
+		return
+	}
+
+	ca, cb := Concrete(a), Concrete(b)
+	if !ca.IsValid() || !cb.IsValid() || ca.Type() != cb.Type() {
+		if ca.IsValid() || cb.IsValid() {
+			d.modified(path, a, b)
+		}
+		return
+	}
+	if isVisiting(a, d.visiting) {
+		return
+	}
+
+	switch ca.Kind() {
+	case reflect.Struct:
+		visit(d.visiting, a, func() {
+			for _, node := range children(a, d.methods) {
+				d.diff(path.append(node), getNode(node, a), getNode(node, b))
+			}
+		})
+	case reflect.Map:
+		if ca.IsNil() != cb.IsNil() {
+			d.modified(path, a, b)
+			return
+		}
+		visit(d.visiting, a, func() {
+			for _, node := range children(a, d.methods) {
+				d.diff(path.append(node), getNode(node, a), getNode(node, b))
+			}
+			for _, node := range children(b, d.methods) {
+				if !hasNode(node, a) {
+					d.diff(path.append(node), invalidValue, getNode(node, b))
+				}
+			}
+		})
+	case reflect.Slice, reflect.Array:
+		if ca.Kind() == reflect.Slice && ca.IsNil() != cb.IsNil() {
+			d.modified(path, a, b)
+			return
+		}
+		visit(d.visiting, a, func() {
+			if key, ok := d.keyFor(path, ca.Type().Elem()); ok {
+				d.diffKeyed(path, ca, cb, key)
+			} else {
+				d.diffIndexed(path, ca, cb)
+			}
+		})
+	default:
+		if !ca.CanInterface() || !cb.CanInterface() || !reflect.DeepEqual(ca.Interface(), cb.Interface()) {
+			d.modified(path, a, b)
+		}
+	}
+}
+
+// Adds a modified change.
+func (d *differ) modified(path Path, a, b reflect.Value) {
+	d.changes = append(d.changes, Change{Kind: ChangeModified, Path: path, Old: a, New: b})
+}
+
+// Compares the elements of two slices or arrays by index.
+func (d *differ) diffIndexed(path Path, a, b reflect.Value) {
+	nodes := GetTypeNodes(a.Type())
+	for i := 0; i < a.Len() || i < b.Len(); i++ {
+		next := nodes.ForKey(i)
+		if next == nil {
+			return
+		}
+		ea, eb := invalidValue, invalidValue
+		if i < a.Len() {
+			ea = a.Index(i)
+		}
+		if i < b.Len() {
+			eb = b.Index(i)
+		}
+		d.diff(path.append(*next), ea, eb)
+	}
+}
+
+// Compares the elements of two slices or arrays with the same value at the
+// key sub-path.
+func (d *differ) diffKeyed(path Path, a, b reflect.Value, key Path) {
+	indices := make(map[string]int, a.Len())
+	for i := 0; i < a.Len(); i++ {
+		if k, ok := diffKeyOf(key, a.Index(i)); ok {
+			indices[k] = i
+		}
+	}
+
+	nodes := GetTypeNodes(a.Type())
+	matched := make(map[int]bool, b.Len())
+	for i := 0; i < b.Len(); i++ {
+		next := nodes.ForKey(i)
+		if next == nil {
+			return
+		}
+		eb := b.Index(i)
+		k, ok := diffKeyOf(key, eb)
+		if j, found := indices[k]; ok && found && !matched[j] {
+			matched[j] = true
+			d.diff(path.append(*next), a.Index(j), eb)
+		} else {
+			d.diff(path.append(*next), invalidValue, eb)
+		}
+	}
+	for i := 0; i < a.Len(); i++ {
+		if next := nodes.ForKey(i); next != nil && !matched[i] {
+			d.diff(path.append(*next), a.Index(i), invalidValue)
+		}
+	}
+}
+
+// Returns the key of the element as a string.
+func diffKeyOf(key Path, element reflect.Value) (string, bool) {
+	value, err := key.Get(element)
+	if err != nil || !value.CanInterface() {
+		return "", false
+	}
+	return ToString(value.Interface()), true
+}
+
+// Returns the key sub-path for the slices at the path, if they're keyed.
+func (d *differ) keyFor(path Path, elem reflect.Type) (Path, bool) {
+	for _, keyed := range d.keyed {
+		if len(keyed.slices.nodes) != len(path.nodes) || !keyed.slices.overlaps(path) {
+			continue
+		}
+		if key, err := ParsePath(elem, keyed.key); err == nil {
+			return key, true
+		}
+	}
+	return Path{}, false
+}
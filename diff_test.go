@@ -0,0 +1,105 @@
+package refstr
+
+import (
+	"fmt"
+	"testing"
+)
+
+type diffSecret struct {
+	Name   string
+	secret int
+}
+
+type diffAny struct {
+	Any any
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     any
+		options  []DiffOption
+		expected []string
+	}{{
+		name:     "equal",
+		a:        testConfig{Name: "a", Servers: []testServer{{Host: "x"}}, Limits: map[string]int{"k": 1}},
+		b:        testConfig{Name: "a", Servers: []testServer{{Host: "x"}}, Limits: map[string]int{"k": 1}},
+		expected: []string{},
+	}, {
+		name:     "modified leaf",
+		a:        diffSecret{Name: "a", secret: 1},
+		b:        diffSecret{Name: "b", secret: 2},
+		expected: []string{"modified Name a b"},
+	}, {
+		name:     "pointer",
+		a:        testConfig{Backup: &testServer{Port: 1}},
+		b:        testConfig{Backup: &testServer{Port: 2}},
+		expected: []string{"modified Backup.Port 1 2"},
+	}, {
+		name:     "nil pointer",
+		a:        testConfig{},
+		b:        testConfig{Backup: &testServer{Port: 2}},
+		expected: []string{"modified Backup <nil> &{Host: Port:2}"},
+	}, {
+		name:     "map keys",
+		a:        testConfig{Limits: map[string]int{"a": 1, "b": 2}},
+		b:        testConfig{Limits: map[string]int{"b": 3, "c": 4}},
+		expected: []string{"removed Limits[a] 1 <invalid reflect.Value>", "modified Limits[b] 2 3", "added Limits[c] <invalid reflect.Value> 4"},
+	}, {
+		name:     "indices",
+		a:        testConfig{Servers: []testServer{{Host: "x"}, {Host: "y"}}},
+		b:        testConfig{Servers: []testServer{{Host: "y"}}},
+		expected: []string{"modified Servers[0].Host x y", "removed Servers[1] {Host:y Port:0} <invalid reflect.Value>"},
+	}, {
+		name:     "keyed",
+		a:        testConfig{Servers: []testServer{{Host: "x"}, {Host: "y", Port: 1}}},
+		b:        testConfig{Servers: []testServer{{Host: "y", Port: 2}, {Host: "z"}}},
+		options:  []DiffOption{DiffKeyedBy("Servers", "Host")},
+		expected: []string{"modified Servers[0].Port 1 2", "added Servers[1] <invalid reflect.Value> {Host:z Port:0}", "removed Servers[0] {Host:x Port:0} <invalid reflect.Value>"},
+	}, {
+		name:     "methods",
+		a:        testConfig{Servers: []testServer{{Host: "x", Port: 1}}},
+		b:        testConfig{Servers: []testServer{{Host: "x", Port: 2}}},
+		options:  []DiffOption{DiffMethods()},
+		expected: []string{"modified Servers[0].Port 1 2", "modified Servers[0].Address x:1 x:2"},
+	}, {
+		name:     "interface",
+		a:        diffAny{Any: map[string]int{"a": 1}},
+		b:        diffAny{Any: map[string]int{"a": 2}},
+		expected: []string{"modified Any[a] 1 2"},
+	}, {
+		name:     "interface type",
+		a:        diffAny{Any: 1},
+		b:        diffAny{Any: "1"},
+		expected: []string{"modified Any 1 1"},
+	}}
+
+	kinds := map[ChangeKind]string{ChangeAdded: "added", ChangeRemoved: "removed", ChangeModified: "modified"}
+
+	for _, test := range tests {
+		changes := Diff(test.a, test.b, test.options...)
+		actual := make([]string, len(changes))
+		for i, c := range changes {
+			actual[i] = fmt.Sprintf("%s %s %+v %+v", kinds[c.Kind], c.Path.String(), c.Old, c.New)
+		}
+		if !StringEqual(actual, test.expected) {
+			t.Errorf("[%s] expected %v but got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+	a := &node{Value: 1}
+	a.Next = a
+	b := &node{Value: 2}
+	b.Next = b
+
+	changes := Diff(a, b)
+	if len(changes) != 1 || changes[0].Path.String() != "Value" {
+		t.Errorf("expected only the value to change but got %v", changes)
+	}
+}
@@ -0,0 +1,208 @@
+package refstr
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+var textMarshalerType = TypeOf[encoding.TextMarshaler]()
+
+// Encodes the value into a string that this decoder can decode back into
+// the same value.
+func (d Decoder) Encode(v any) (string, error) {
+	return d.encode(Reflect(v))
+}
+
+// Encodes the given value, this is the inverse of Parse.
+func (d *Decoder) encode(rv reflect.Value) (string, error) {
+	if !rv.IsValid() {
+		return "", nil
+	}
+	if rv.Kind() != reflect.Pointer && rv.Kind() != reflect.Interface {
+		if marshaller, ok := textMarshaler(rv); ok {
+			text, err := marshaller.MarshalText()
+			if err != nil {
+				return "", fmt.Errorf("error marshalling text %v: %w", rv.Type(), err)
+			}
+			return string(text), nil
+		}
+	}
+
+	if enum, ok := d.Enums[rv.Type()]; ok && rv.Kind() != reflect.Interface && rv.CanInterface() {
+		if err := validateEnum(rv.Type(), enum); err != nil {
+			return "", err
+		}
+		for _, e := range enum {
+			if reflect.ValueOf(e.Value).Convert(rv.Type()).Interface() == rv.Interface() {
+				return e.Name, nil
+			}
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return "", nil
+		}
+		return d.encode(rv.Elem())
+	case reflect.Bool:
+		if d.Locale != nil {
+			return d.Locale.FormatBool(rv.Bool()), nil
+		}
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Kind() == reflect.Int32 && d.Chars != CharsOff && utf8.ValidRune(rune(rv.Int())) {
+			if char, ok := d.encodeChar(rune(rv.Int())); ok {
+				return char, nil
+			}
+		}
+		if d.Locale != nil {
+			return d.Locale.FormatInt(rv.Int()), nil
+		}
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Kind() == reflect.Uint8 && d.Chars != CharsOff && rv.Uint() < utf8.RuneSelf {
+			if char, ok := d.encodeChar(rune(rv.Uint())); ok {
+				return char, nil
+			}
+		}
+		if d.Locale != nil {
+			return d.Locale.FormatUint(rv.Uint()), nil
+		}
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		bits := kindBits[rv.Kind()]
+		if d.Locale != nil {
+			return d.Locale.FormatFloat(rv.Float(), bits), nil
+		}
+		return strconv.FormatFloat(rv.Float(), 'g', -1, bits), nil
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(rv.Complex(), 'g', -1, kindBits[rv.Kind()]), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Array:
+		if rv.Type().Elem() == byteType && d.Bytes != BytesRaw {
+			bytes := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(bytes), rv)
+			return d.encodeBytes(bytes), nil
+		}
+		values, err := d.encodeElements(rv)
+		if err != nil {
+			return "", err
+		}
+		return d.Array.Join(values), nil
+	case reflect.Slice:
+		if isBytes(rv.Type()) {
+			return d.encodeBytes(rv.Bytes()), nil
+		}
+		if d.Ranges && isRangeSlice(rv.Type()) {
+			return d.encodeRanges(rv)
+		}
+		values, err := d.encodeElements(rv)
+		if err != nil {
+			return "", err
+		}
+		return d.Slice.Join(values), nil
+	case reflect.Map:
+		if isSetMap(rv.Type()) {
+			return d.encodeSet(rv)
+		}
+		keyValues := make([][2]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := d.encode(iter.Key())
+			if err != nil {
+				return "", err
+			}
+			value, err := d.encode(iter.Value())
+			if err != nil {
+				return "", err
+			}
+			keyValues = append(keyValues, [2]string{key, value})
+		}
+		sort.Slice(keyValues, func(i, j int) bool {
+			return keyValues[i][0] < keyValues[j][0]
+		})
+		return d.Map.JoinKeyValues(keyValues), nil
+	case reflect.Struct:
+		fields := encodedFields(rv.Type())
+		keyValues := make([][2]string, 0, len(fields))
+		for _, field := range fields {
+			fieldValue, err := rv.FieldByIndexErr(field.Index)
+			if err != nil {
+				continue
+			}
+			value, err := d.encode(fieldValue)
+			if err != nil {
+				return "", err
+			}
+			keyValues = append(keyValues, [2]string{field.Name, value})
+		}
+		return d.Struct.JoinKeyValues(keyValues), nil
+	default:
+		return "", fmt.Errorf("unsupported kind %v", rv.Type())
+	}
+}
+
+// Encodes a set-like map as a sorted list of its members. Keys with a false
+// value are not members and are not written.
+func (d *Decoder) encodeSet(rv reflect.Value) (string, error) {
+	members := make([]string, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		if iter.Value().Kind() == reflect.Bool && !iter.Value().Bool() {
+			continue
+		}
+		member, err := d.encode(iter.Key())
+		if err != nil {
+			return "", err
+		}
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return d.Slice.Join(members), nil
+}
+
+// Encodes each element of the given slice or array.
+func (d *Decoder) encodeElements(rv reflect.Value) ([]string, error) {
+	values := make([]string, rv.Len())
+	for i := range values {
+		value, err := d.encode(rv.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Returns the text marshaler for the given value if it has one.
+func textMarshaler(rv reflect.Value) (encoding.TextMarshaler, bool) {
+	if rv.Type().Implements(textMarshalerType) {
+		return rv.Interface().(encoding.TextMarshaler), true
+	}
+	if rv.CanAddr() && reflect.PointerTo(rv.Type()).Implements(textMarshalerType) {
+		return rv.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// Returns the exported fields of the struct type which are encoded, including
+// fields promoted from embedded structs.
+func encodedFields(rt reflect.Type) []reflect.StructField {
+	visible := reflect.VisibleFields(rt)
+	fields := make([]reflect.StructField, 0, len(visible))
+	for _, f := range visible {
+		if !f.IsExported() || (f.Anonymous && ConcreteType(f.Type).Kind() == reflect.Struct) {
+			continue
+		}
+		if field, ok := rt.FieldByName(f.Name); ok && len(field.Index) == len(f.Index) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
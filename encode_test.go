@@ -0,0 +1,76 @@
+package refstr
+
+import (
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	type Point struct{ X, Y float32 }
+	type Named struct {
+		Point
+		Name string
+	}
+
+	tests := []struct {
+		name    string
+		value   any
+		encoded string
+	}{{
+		name:    "int",
+		value:   int(-45),
+		encoded: "-45",
+	}, {
+		name:    "float32",
+		value:   float32(0.34),
+		encoded: "0.34",
+	}, {
+		name:    "bool",
+		value:   true,
+		encoded: "true",
+	}, {
+		name:    "*int",
+		value:   Ptr(34),
+		encoded: "34",
+	}, {
+		name:    "[2]int",
+		value:   [2]int{3, 4},
+		encoded: "[3 4]",
+	}, {
+		name:    "[]bool",
+		value:   []bool{true, false},
+		encoded: "[true false]",
+	}, {
+		name:    "map[string]int",
+		value:   map[string]int{"b": 5, "a": 2},
+		encoded: "map[a:2 b:5]",
+	}, {
+		name:    "Point",
+		value:   Point{X: 2, Y: 5.4},
+		encoded: "{X:2 Y:5.4}",
+	}, {
+		name:    "Named",
+		value:   Named{Point: Point{X: 1, Y: 2}, Name: "A"},
+		encoded: "{X:1 Y:2 Name:A}",
+	}}
+
+	for _, test := range tests {
+		encoded, err := Encode(test.value)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error during Encode: %v", test.name, err)
+			continue
+		}
+		if encoded != test.encoded {
+			t.Errorf("[%s] Expected %s but got %s", test.name, test.encoded, encoded)
+			continue
+		}
+
+		decoded, err := DecodeType(Reflect(test.value).Type(), encoded)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error during DecodeType: %v", test.name, err)
+			continue
+		}
+		if !StringEqual(Concrete(decoded).Interface(), Concrete(test.value).Interface()) {
+			t.Errorf("[%s] Expected round trip %+v but got %+v", test.name, test.value, decoded)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package refstr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The comparison operators of filters, longest first so they're found before
+// the operators they start with.
+var filterOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// A condition on the fields, keys or indices of a value which selects the
+// ones that are matched.
+type pathFilter struct {
+	// The key pattern of a regex filter.
+	keys *regexp.Regexp
+	// The sub-path of a comparison filter.
+	path Path
+	// The comparison operator or empty if the sub-path value must be non-zero.
+	operator string
+	// The literal compared to and its decoded value if the type of the
+	// sub-path is known.
+	literal string
+	value   reflect.Value
+	decoder *Decoder
+}
+
+// Returns a node which matches the fields, keys or indices of the value of
+// the given type which pass the filter. The filter is either a regex for keys
+// like /^J/ or a comparison on the sub-path of each element like ?Age>=18.
+// A comparison without an operator like ?Enabled matches non-zero values.
+func (d *Decoder) filterNode(rt reflect.Type, text string) (Node, error) {
+	var elem reflect.Type
+	if rt != nil {
+		switch c := ConcreteType(rt); c.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			elem = c.Elem()
+		}
+	}
+
+	filter := &pathFilter{decoder: d}
+	if strings.HasPrefix(text, "/") {
+		pattern := strings.ReplaceAll(text[1:len(text)-1], `\/`, "/")
+		keys, err := regexp.Compile(pattern)
+		if err != nil {
+			return Node{}, err
+		}
+		filter.keys = keys
+	} else if err := d.parseFilter(filter, elem, text[1:]); err != nil {
+		return Node{}, err
+	}
+
+	return Node{
+		Key:       text,
+		KeyString: text,
+		Type:      elem,
+		Kind:      NodeFilter,
+		Expand: func(n Node, rv reflect.Value) [][]Node {
+			matches := make([][]Node, 0)
+			for _, child := range childNodes(rv) {
+				if filter.matches(child, getNode(child, rv)) {
+					matches = append(matches, []Node{child})
+				}
+			}
+			return matches
+		},
+	}, nil
+}
+
+// Parses the sub-path, operator and literal of a comparison filter.
+func (d *Decoder) parseFilter(filter *pathFilter, elem reflect.Type, text string) error {
+	left, right := text, ""
+	if at, operator := findOperator(text); at != -1 {
+		left, right = text[:at], strings.TrimSpace(text[at+len(operator):])
+		filter.operator = operator
+	}
+
+	path, err := d.parsePath(NewPath(elem), strings.TrimSpace(left))
+	if err != nil {
+		return err
+	}
+	filter.path = path
+
+	if filter.operator == "" {
+		return nil
+	}
+	if strings.HasPrefix(right, `"`) {
+		if right, err = strconv.Unquote(right); err != nil {
+			return fmt.Errorf("invalid literal %s: %w", right, err)
+		}
+	}
+	filter.literal = right
+
+	if rt := path.Type(); rt != nil && ConcreteType(rt).Kind() != reflect.Interface {
+		value, err := d.Parse(right, rt)
+		if err != nil {
+			return err
+		}
+		filter.value = Concrete(value)
+		if !isOrdered(filter.value) && filter.operator != "==" && filter.operator != "!=" {
+			return fmt.Errorf("%v can't be compared with %s", rt, filter.operator)
+		}
+	}
+	return nil
+}
+
+// Returns whether the child node with the given value passes the filter.
+func (f *pathFilter) matches(child Node, value reflect.Value) bool {
+	if f.keys != nil {
+		key := child.KeyString
+		if child.Kind == NodeMapKey {
+			if encoded, err := f.decoder.encode(Reflect(child.Key)); err == nil {
+				key = encoded
+			}
+		}
+		return f.keys.MatchString(key)
+	}
+	if !value.IsValid() {
+		return false
+	}
+
+	matches, _ := f.path.GetAll(value)
+	for _, m := range matches {
+		if m.Err == nil && f.compare(Concrete(m.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the value of a sub-path passes the comparison.
+func (f *pathFilter) compare(value reflect.Value) bool {
+	if !value.IsValid() || !value.CanInterface() {
+		return false
+	}
+	if f.operator == "" {
+		return !value.IsZero()
+	}
+
+	literal := f.value
+	if !literal.IsValid() || literal.Type() != value.Type() {
+		parsed, err := f.decoder.Parse(f.literal, value.Type())
+		if err != nil {
+			return false
+		}
+		literal = parsed
+	}
+
+	switch f.operator {
+	case "==":
+		return reflect.DeepEqual(value.Interface(), literal.Interface())
+	case "!=":
+		return !reflect.DeepEqual(value.Interface(), literal.Interface())
+	}
+
+	order, ok := compareOrdered(value, literal)
+	if !ok {
+		return false
+	}
+	switch f.operator {
+	case ">=":
+		return order >= 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order < 0
+	}
+}
+
+// Returns the index and operator of the first operator in the filter outside
+// of quotes and brackets or -1 if there is none.
+func findOperator(text string) (int, string) {
+	depth := 0
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			for _, operator := range filterOperators {
+				if strings.HasPrefix(text[i:], operator) {
+					return i, operator
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+// Reads the filter in brackets starting at i, returning the filter and the
+// index of the closing bracket. Regexes end at the first unescaped slash and
+// comparisons at the first bracket outside of quotes and nested brackets.
+func scanPathFilter(s string, i int) (string, int, error) {
+	if s[i] == '/' {
+		for end := i + 1; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+			} else if s[end] == '/' {
+				return s[i : end+1], end + 1, nil
+			}
+		}
+		return "", len(s), errors.New("missing closing /")
+	}
+
+	depth := 0
+	quoted := false
+	for end := i; end < len(s); end++ {
+		switch c := s[end]; {
+		case quoted && c == '\\':
+			end++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			depth++
+		case c == ']' && depth == 0:
+			return s[i:end], end, nil
+		case c == ']':
+			depth--
+		}
+	}
+	return "", len(s), errors.New("missing ]")
+}
+
+// Returns whether the value can be compared with <, <=, > and >=.
+func isOrdered(rv reflect.Value) bool {
+	_, ok := compareOrdered(rv, rv)
+	return ok
+}
+
+// Compares numbers and strings, returning -1, 0 or 1 and whether they could
+// be compared.
+func compareOrdered(a, b reflect.Value) (int, bool) {
+	if a.Kind() != b.Kind() {
+		return 0, false
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareWith(a.Int() < b.Int(), a.Int() > b.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareWith(a.Uint() < b.Uint(), a.Uint() > b.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return compareWith(a.Float() < b.Float(), a.Float() > b.Float()), true
+	case reflect.String:
+		return strings.Compare(a.String(), b.String()), true
+	}
+	return 0, false
+}
+
+// Returns -1 if less, 1 if greater, otherwise 0.
+func compareWith(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package refstr
+
+import (
+	"errors"
+	"testing"
+)
+
+type filterUser struct {
+	Name    string
+	Age     int
+	Admin   bool
+	Tags    map[string]string
+	Manager *filterUser
+}
+
+type filterHost struct{ Name, Region string }
+
+type filterRoot struct {
+	Users  []filterUser
+	Hosts  [3]filterHost
+	ByName map[string]filterUser
+	Scores map[string]float64
+	Names  []string
+	Any    []any
+}
+
+func newFilterRoot() *filterRoot {
+	ann := filterUser{Name: "Ann", Age: 40, Admin: true}
+	return &filterRoot{
+		Users: []filterUser{
+			ann,
+			{Name: "Bob", Age: 12, Manager: &ann, Tags: map[string]string{"role": "admin"}},
+			{Name: "Doe, J", Age: 18},
+		},
+		Hosts:  [3]filterHost{{"a", "eu"}, {"b", "us"}, {"c", "eu"}},
+		ByName: map[string]filterUser{"Jane": {Name: "Jane", Age: 30}, "John": {Name: "John", Age: 31, Admin: true}, "Ann": ann},
+		Scores: map[string]float64{"x": 0.25, "y": 0.75},
+		Names:  []string{"Ann", "Bob"},
+		Any:    []any{filterUser{Name: "Ann", Age: 40}, filterHost{Name: "h"}, 5},
+	}
+}
+
+func TestFilterGetAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		matches []string
+	}{{
+		name:    "compare",
+		path:    "Users[?Age>=18].Name",
+		matches: []string{"Users[0].Name", "Users[2].Name"},
+	}, {
+		name:    "compare with spaces",
+		path:    "Users[?Age < 18].Name",
+		matches: []string{"Users[1].Name"},
+	}, {
+		name:    "equals in array",
+		path:    "Hosts[?Region==eu].Name",
+		matches: []string{"Hosts[0].Name", "Hosts[2].Name"},
+	}, {
+		name:    "not equals",
+		path:    "Hosts[?Region!=eu]",
+		matches: []string{"Hosts[1]"},
+	}, {
+		name:    "regex keys",
+		path:    "ByName[/^J/].Age",
+		matches: []string{"ByName[Jane].Age", "ByName[John].Age"},
+	}, {
+		name:    "regex with brackets",
+		path:    `ByName[/^[A-J]a/]`,
+		matches: []string{"ByName[Jane]"},
+	}, {
+		name:    "non-zero",
+		path:    "ByName[?Admin]",
+		matches: []string{"ByName[Ann]", "ByName[John]"},
+	}, {
+		name:    "nested sub-path",
+		path:    "Users[?Tags[role]==admin].Name",
+		matches: []string{"Users[1].Name"},
+	}, {
+		name:    "quoted literal",
+		path:    `Users[?Name=="Doe, J"]`,
+		matches: []string{"Users[2]"},
+	}, {
+		name:    "through nil pointers",
+		path:    "Users[?Manager.Name==Ann].Name",
+		matches: []string{"Users[1].Name"},
+	}, {
+		name:    "element itself",
+		path:    "Names[?==Bob]",
+		matches: []string{"Names[1]"},
+	}, {
+		name:    "floats",
+		path:    "Scores[?>0.5]",
+		matches: []string{"Scores[y]"},
+	}, {
+		name:    "interface elements",
+		path:    "Any[?Age>3]",
+		matches: []string{"Any[0]"},
+	}}
+
+	for _, test := range tests {
+		root := newFilterRoot()
+		ref, err := NewRef(root).NextPath(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if ref.Path().String() != test.path {
+			t.Errorf("[%s] expected path %s but got %s", test.name, test.path, ref.Path().String())
+		}
+		matches, err := ref.GetAll()
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		paths := make([]string, len(matches))
+		for i, m := range matches {
+			paths[i] = m.Path.String()
+		}
+		if !StringEqual(paths, test.matches) {
+			t.Errorf("[%s] expected matches %v but got %v", test.name, test.matches, paths)
+		}
+	}
+}
+
+func TestFilterSetAll(t *testing.T) {
+	root := newFilterRoot()
+	ref, _ := NewRef(root).NextPath("Users[?Age<18].Age")
+	if _, err := ref.SetAll(18); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if root.Users[1].Age != 18 || root.Users[0].Age != 40 {
+		t.Errorf("expected only minors to be changed but got %v", root.Users)
+	}
+
+	ref, _ = NewRef(root).NextPath("ByName[/^J/].Admin")
+	if _, err := ref.SetAll(false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if root.ByName["John"].Admin || !root.ByName["Ann"].Admin {
+		t.Errorf("expected only J names to be changed but got %v", root.ByName)
+	}
+}
+
+func TestFilterGetSet(t *testing.T) {
+	root := newFilterRoot()
+	ref, _ := NewRef(root).NextPath(`Users[?Name=="Bob"].Age`)
+	if value, err := ref.Get(); err != nil || value.Interface() != 12 {
+		t.Fatalf("expected the single match to be gotten but got %v %v", value, err)
+	}
+	if err := ref.Set(13); err != nil || root.Users[1].Age != 13 {
+		t.Fatalf("expected the single match to be set but got %v %v", root.Users, err)
+	}
+
+	store := NewStore(root)
+	changes := make([]Change, 0)
+	store.Subscribe("", func(c Change) { changes = append(changes, c) })
+	ref, _ = store.Ref().NextPath("Hosts[?Name==c].Region")
+	if err := ref.Set("us"); err != nil || root.Hosts[2].Region != "us" {
+		t.Fatalf("expected the single match to be set through a store but got %v %v", root.Hosts, err)
+	}
+	if len(changes) != 1 || changes[0].Path.String() != "Hosts[2].Region" {
+		t.Errorf("expected the change at the matched path but got %v", changes)
+	}
+
+	for _, path := range []string{"Users[?Age>=18].Age", "Users[?Age>99].Age"} {
+		ref, _ = NewRef(root).NextPath(path)
+		if _, err := ref.Get(); !errors.Is(err, ErrFilterMatches) {
+			t.Errorf("[%s] expected the filter to not match one value but got %v", path, err)
+		}
+		if err := ref.Set(1); !errors.Is(err, ErrFilterMatches) {
+			t.Errorf("[%s] expected the filter to not match one value but got %v", path, err)
+		}
+	}
+
+	ref, _ = NewRef(root).NextPath("Users[*].Age")
+	if _, err := ref.Get(); !errors.Is(err, ErrPathPattern) {
+		t.Errorf("expected wildcards to not be gotten but got %v", err)
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	paths := []string{
+		"Users[?Age>=abc]",
+		"Users[?Tags>x]",
+		"Users[?Height>1]",
+		"ByName[/(/]",
+		"ByName[/^J]",
+		"Users[?Age>=18",
+	}
+	for _, path := range paths {
+		if _, err := ParsePath(TypeOf[filterRoot](), path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("[%s] expected invalid path but got %v", path, err)
+		}
+	}
+}
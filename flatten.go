@@ -0,0 +1,104 @@
+package refstr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Returns every leaf of the value by its path, like ByName[John].Name.First,
+// with its encoded value using the default decoder. See Decoder.Flatten.
+func Flatten(v any) map[string]string {
+	return defaultDecoder.Load().Flatten(v)
+}
+
+// Sets the flattened values back into dst, which needs to be a pointer, using
+// the default decoder. See Decoder.Unflatten.
+func Unflatten(dst any, m map[string]string) error {
+	return defaultDecoder.Load().Unflatten(dst, m)
+}
+
+// Returns every leaf of the value by its path with its encoded value. Leaves
+// are values this decoder decodes as a whole, like numbers, strings, bytes and
+// types with parsers, enums or text unmarshalers. Empty maps, slices and
+// structs are leaves as well so they're not lost. Nil values and values which
+// can't be encoded are left out.
+func (d Decoder) Flatten(v any) map[string]string {
+	flat := make(map[string]string)
+	Walk(v, func(p Path, rv reflect.Value) WalkAction {
+		c := Concrete(rv)
+		if !c.IsValid() {
+			return WalkSkip
+		}
+		if !d.isLeaf(c.Type()) && len(childNodes(rv)) > 0 {
+			return WalkContinue
+		}
+		if encoded, err := d.encode(rv); err == nil {
+			flat[d.FormatPath(p)] = encoded
+		}
+		return WalkSkip
+	})
+	return flat
+}
+
+// Sets the flattened values back into dst, which needs to be a pointer, by
+// decoding each path and value with this decoder. Missing maps, slices and
+// pointers along the paths are created.
+func (d Decoder) Unflatten(dst any, m map[string]string) error {
+	root := Reflect(dst)
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path, err := d.ParsePath(root.Type(), key)
+		if err != nil {
+			return err
+		}
+		var value any = m[key]
+		if rt := path.Type(); rt != nil {
+			if value, err = d.decodeFlat(rt, m[key]); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		if err := path.Set(root, value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Decodes a flattened value. Empty maps, slices and structs are created
+// rather than decoded since they have no values to decode.
+func (d *Decoder) decodeFlat(rt reflect.Type, s string) (any, error) {
+	if !d.isLeaf(ConcreteType(rt)) {
+		empty := InitType(rt)
+		if encoded, err := d.encode(empty); err == nil && encoded == s {
+			return empty.Interface(), nil
+		}
+	}
+	return d.DecodeType(rt, s)
+}
+
+// Returns whether this decoder decodes values of the concrete type as a
+// whole rather than by their fields, keys or elements.
+func (d *Decoder) isLeaf(rt reflect.Type) bool {
+	if _, ok := d.Parsers[rt]; ok {
+		return true
+	}
+	if _, ok := d.Enums[rt]; ok {
+		return true
+	}
+	if rt.Implements(textUnmarshalerType) || reflect.PointerTo(rt).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch rt.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return isBytes(rt)
+	case reflect.Array:
+		return rt.Elem() == byteType
+	}
+	return true
+}
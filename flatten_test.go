@@ -0,0 +1,81 @@
+package refstr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type flatConfig struct {
+	Name     string
+	Enabled  bool
+	Ratio    float64
+	Signal   complex128
+	Started  time.Time
+	Key      []byte
+	Pair     [2]int
+	Timeout  time.Duration
+	Primary  *testServer
+	Backup   *testServer
+	Servers  []testServer
+	ByRegion map[string]map[int]string
+	Limit    *int
+	Tags     []string
+	Labels   map[string]string
+	Nothing  struct{}
+}
+
+func TestFlatten(t *testing.T) {
+	limit := 5
+	config := flatConfig{
+		Name:     "config",
+		Enabled:  true,
+		Ratio:    0.25,
+		Signal:   complex(1, -2),
+		Started:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Key:      []byte{1, 2, 3},
+		Pair:     [2]int{7, 8},
+		Timeout:  time.Second,
+		Primary:  &testServer{Host: "a", Port: 80},
+		Servers:  []testServer{{Host: "b"}, {Host: "c", Port: 443}},
+		ByRegion: map[string]map[int]string{"us east": {1: "x", 2: "y"}},
+		Limit:    &limit,
+		Tags:     []string{},
+		Labels:   map[string]string{},
+	}
+
+	flat := Flatten(config)
+	expected := map[string]string{
+		"Primary.Host":           "a",
+		"Servers[1].Port":        "443",
+		`ByRegion["us east"][2]`: "y",
+		"Limit":                  "5",
+		"Pair[1]":                "8",
+	}
+	for key, value := range expected {
+		if flat[key] != value {
+			t.Errorf("expected %s to be %q but got %q in %v", key, value, flat[key], flat)
+		}
+	}
+	if _, ok := flat["Backup"]; ok {
+		t.Errorf("expected nil pointers to be left out but got %v", flat)
+	}
+
+	unflattened := flatConfig{}
+	if err := Unflatten(&unflattened, flat); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(config, unflattened) {
+		t.Errorf("expected %+v but got %+v from %v", config, unflattened, flat)
+	}
+}
+
+func TestUnflattenInvalid(t *testing.T) {
+	config := flatConfig{}
+	if err := Unflatten(&config, map[string]string{"Missing": "1"}); err == nil {
+		t.Errorf("expected an invalid path to fail")
+	}
+	if err := Unflatten(&config, map[string]string{"Pair[0]": "x"}); err == nil {
+		t.Errorf("expected an invalid value to fail")
+	}
+}
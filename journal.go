@@ -0,0 +1,164 @@
+package refstr
+
+import (
+	"errors"
+	"sync"
+)
+
+// Undo was called without a change to undo.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Redo was called without an undone change to redo.
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// Records the changes made through a store so they can be undone and redone.
+// Undoing restores the previous state exactly, removing the map keys and
+// slice elements a change created. Making a new change clears the changes
+// that can be redone.
+type Journal struct {
+	store *Store
+	sub   *subscription
+	lock  sync.Mutex
+	undos []Change
+	redos []Change
+}
+
+// Creates a journal which records every change made through the store.
+func NewJournal(store *Store) *Journal {
+	j := &Journal{store: store}
+	j.sub = store.subscribe(NewPath(store.root.Type()), j.record)
+	return j
+}
+
+// Records a change made through the store.
+func (j *Journal) record(change Change) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.undos = append(j.undos, change)
+	j.redos = nil
+}
+
+// Stops recording changes.
+func (j *Journal) Close() {
+	j.store.unsubscribe(j.sub)
+}
+
+// Returns the changes which can be undone, oldest first. A change which
+// added a value has an invalid Old and Kind ChangeAdded.
+func (j *Journal) Changes() []Change {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return append([]Change(nil), j.undos...)
+}
+
+// Returns whether there is a change to undo.
+func (j *Journal) CanUndo() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return len(j.undos) > 0
+}
+
+// Returns whether there is an undone change to redo.
+func (j *Journal) CanRedo() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return len(j.redos) > 0
+}
+
+// Forgets the recorded changes.
+func (j *Journal) Clear() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.undos = nil
+	j.redos = nil
+}
+
+// Undoes the last change. Subscribers of the store other than the journal
+// are notified of the changes made to undo it.
+func (j *Journal) Undo() error {
+	return j.replay(&j.undos, &j.redos, ErrNothingToUndo, func(c Change) error {
+		return j.store.write(func() (*Change, error) {
+			return j.store.undo(c, j.sub)
+		})
+	})
+}
+
+// Makes the last undone change again. Subscribers of the store other than
+// the journal are notified of the changes made to redo it.
+func (j *Journal) Redo() error {
+	return j.replay(&j.redos, &j.undos, ErrNothingToRedo, func(c Change) error {
+		return j.store.write(func() (*Change, error) {
+			return j.store.redo(c, j.sub)
+		})
+	})
+}
+
+// Takes the last change from one list and applies it, moving it to the other
+// list if it succeeds and putting it back if it fails.
+func (j *Journal) replay(from, to *[]Change, empty error, apply func(Change) error) error {
+	j.lock.Lock()
+	if len(*from) == 0 {
+		j.lock.Unlock()
+		return empty
+	}
+	last := len(*from) - 1
+	change := (*from)[last]
+	*from = (*from)[:last]
+	j.lock.Unlock()
+
+	err := apply(change)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err != nil {
+		*from = append(*from, change)
+		return err
+	}
+	*to = append(*to, change)
+	return nil
+}
+
+// Restores the state before the change, notifying the subscribers except
+// the skipped one.
+func (s *Store) undo(c Change, skip *subscription) (*Change, error) {
+	switch c.Kind {
+	case ChangeModified:
+		return s.set(c.Path, snapshot(c.Old), skip)
+	case ChangeAdded:
+		if c.inserted {
+			return s.delete(c.Path, skip)
+		}
+		if c.undoValue.IsValid() {
+			return s.reset(c.undoPath, snapshot(c.undoValue), skip)
+		}
+		return s.delete(c.undoPath, skip)
+	}
+
+	end := c.Path.End()
+	switch {
+	case end != nil && end.Kind == NodeIndex:
+		return s.insert(c.Path, snapshot(c.Old), skip)
+	case end != nil && end.Kind == NodeRange:
+		parent := c.Path.parent()
+		start := end.Key.(sliceRange).start
+		removed := rangeNode(end.Type, sliceRange{start: start, end: start, hasStart: true, hasEnd: true})
+		return s.set(parent.append(removed), snapshot(c.Old), skip)
+	}
+	return s.set(c.Path, snapshot(c.Old), skip)
+}
+
+// Makes the change again, notifying the subscribers except the skipped one.
+func (s *Store) redo(c Change, skip *subscription) (*Change, error) {
+	switch {
+	case c.Kind == ChangeRemoved:
+		return s.delete(c.Path, skip)
+	case c.inserted:
+		return s.insert(c.Path, snapshot(c.New), skip)
+	}
+	return s.set(c.Path, snapshot(c.New), skip)
+}
@@ -0,0 +1,251 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ref Ref) error
+	}{{
+		name: "modified",
+		change: func(ref Ref) error {
+			return ref.Next("Name").SetString("changed")
+		},
+	}, {
+		name: "added map key",
+		change: func(ref Ref) error {
+			return ref.Next("Limits").Next("memory").Set(512)
+		},
+	}, {
+		name: "created nested map",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Groups[admins][alice]")
+			return next.Set(1)
+		},
+	}, {
+		name: "created pointer",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Backup.Port")
+			return next.Set(90)
+		},
+	}, {
+		name: "grown slice",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[4].Host")
+			return next.Set("e")
+		},
+	}, {
+		name: "appended",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[+]")
+			return next.Set(testServer{Host: "c"})
+		},
+	}, {
+		name: "inserted",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[0]")
+			return next.Insert(testServer{Host: "z"})
+		},
+	}, {
+		name: "removed map key",
+		change: func(ref Ref) error {
+			return ref.Next("Limits").Next("cpu").Delete()
+		},
+	}, {
+		name: "removed element",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[-2]")
+			return next.Delete()
+		},
+	}, {
+		name: "removed range",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[-1:]")
+			return next.Delete()
+		},
+	}, {
+		name: "deleted field",
+		change: func(ref Ref) error {
+			return ref.Next("Name").Delete()
+		},
+	}, {
+		name: "moved",
+		change: func(ref Ref) error {
+			return ref.Next("Servers").Next(0).Move(1)
+		},
+	}}
+
+	for _, test := range tests {
+		config := &testConfig{
+			Name:    "config",
+			Servers: []testServer{{Host: "a"}, {Host: "b"}},
+			Limits:  map[string]int{"cpu": 2},
+			Groups:  map[string]map[string]int{},
+		}
+		store := NewStore(config)
+		journal := NewJournal(store)
+
+		before := cloneJournalConfig(config)
+		if err := test.change(store.Ref()); err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		after := cloneJournalConfig(config)
+
+		if err := journal.Undo(); err != nil {
+			t.Errorf("[%s] unexpected undo error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, before) {
+			t.Errorf("[%s] expected undo to restore %+v but got %+v", test.name, before, config)
+		}
+		if err := journal.Redo(); err != nil {
+			t.Errorf("[%s] unexpected redo error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, after) {
+			t.Errorf("[%s] expected redo to restore %+v but got %+v", test.name, after, config)
+		}
+	}
+}
+
+func TestJournalNil(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ref Ref) error
+	}{{
+		name: "created map",
+		change: func(ref Ref) error {
+			return ref.Next("Limits").Next("q").Set(4)
+		},
+	}, {
+		name: "created nested map",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Groups[admins][alice]")
+			return next.Set(1)
+		},
+	}, {
+		name: "grown slice",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[2].Host")
+			return next.Set("h")
+		},
+	}, {
+		name: "appended",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[+]")
+			return next.Set(testServer{Host: "c"})
+		},
+	}}
+
+	for _, test := range tests {
+		config := &testConfig{}
+		store := NewStore(config)
+		journal := NewJournal(store)
+
+		if err := test.change(store.Ref()); err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		after := cloneJournalConfig(config)
+		if err := journal.Undo(); err != nil {
+			t.Errorf("[%s] unexpected undo error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, &testConfig{}) {
+			t.Errorf("[%s] expected undo to restore nil values but got %#v", test.name, config)
+		}
+		if err := journal.Redo(); err != nil {
+			t.Errorf("[%s] unexpected redo error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, after) {
+			t.Errorf("[%s] expected redo to restore %+v but got %+v", test.name, after, config)
+		}
+	}
+}
+
+func TestJournalHistory(t *testing.T) {
+	config := &testConfig{Limits: map[string]int{}}
+	store := NewStore(config)
+	journal := NewJournal(store)
+
+	notified := 0
+	store.Subscribe("", func(c Change) { notified++ })
+
+	ref := store.Ref()
+	ref.Next("Name").Set("a")
+	ref.Next("Name").Set("b")
+	ref.Next("Limits").Next("cpu").Set(1)
+
+	if changes := journal.Changes(); len(changes) != 3 || changes[2].Kind != ChangeAdded || changes[0].Old.Interface() != "" {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := journal.Undo(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if err := journal.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected nothing to undo but got %v", err)
+	}
+	if config.Name != "" || len(config.Limits) != 0 {
+		t.Errorf("expected undone changes but got %+v", config)
+	}
+	if notified != 6 {
+		t.Errorf("expected subscribers notified of undos but got %d notifications", notified)
+	}
+	if len(journal.Changes()) != 0 || !journal.CanRedo() {
+		t.Errorf("expected undone changes to be redoable")
+	}
+
+	journal.Redo()
+	ref.Next("Name").Set("c")
+	if journal.CanRedo() {
+		t.Errorf("expected a new change to clear the redos")
+	}
+	if err := journal.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("expected nothing to redo but got %v", err)
+	}
+
+	journal.Close()
+	ref.Next("Name").Set("d")
+	if len(journal.Changes()) != 2 {
+		t.Errorf("expected no changes recorded after closing but got %v", journal.Changes())
+	}
+}
+
+func cloneJournalConfig(c *testConfig) *testConfig {
+	clone := *c
+	if c.Backup != nil {
+		backup := *c.Backup
+		clone.Backup = &backup
+	}
+	if c.Servers != nil {
+		clone.Servers = append([]testServer{}, c.Servers...)
+	}
+	if c.Tags != nil {
+		clone.Tags = append([]string{}, c.Tags...)
+	}
+	if c.Limits != nil {
+		clone.Limits = make(map[string]int, len(c.Limits))
+		for k, v := range c.Limits {
+			clone.Limits[k] = v
+		}
+	}
+	if c.Groups != nil {
+		clone.Groups = make(map[string]map[string]int, len(c.Groups))
+		for k, group := range c.Groups {
+			clone.Groups[k] = make(map[string]int, len(group))
+			for name, v := range group {
+				clone.Groups[k][name] = v
+			}
+		}
+	}
+	return &clone
+}
@@ -0,0 +1,209 @@
+package refstr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A locale controls how numbers and booleans are read and written by a decoder.
+type Locale struct {
+	// The name of the locale, typically a language tag.
+	Name string
+	// The separator between the integer and fractional part of a number.
+	Decimal string
+	// The separator between groups of thousands, if any.
+	Grouping string
+	// Whether a trailing % is accepted on floats, dividing the value by 100.
+	Percent bool
+	// The words accepted as true, the first is used when encoding.
+	Trues []string
+	// The words accepted as false, the first is used when encoding.
+	Falses []string
+}
+
+// English numbers (1,234.56) and words.
+var LocaleEnglish = Locale{
+	Name:     "en",
+	Decimal:  ".",
+	Grouping: ",",
+	Percent:  true,
+	Trues:    []string{"true", "t", "yes", "y", "1"},
+	Falses:   []string{"false", "f", "no", "n", "0", ""},
+}
+
+// German numbers (1.234,56) and words.
+var LocaleGerman = Locale{
+	Name:     "de",
+	Decimal:  ",",
+	Grouping: ".",
+	Percent:  true,
+	Trues:    []string{"wahr", "w", "ja", "j", "true", "1"},
+	Falses:   []string{"falsch", "f", "nein", "n", "false", "0", ""},
+}
+
+// French numbers (1 234,56) and words.
+var LocaleFrench = Locale{
+	Name:     "fr",
+	Decimal:  ",",
+	Grouping: " ",
+	Percent:  true,
+	Trues:    []string{"vrai", "v", "oui", "o", "true", "1"},
+	Falses:   []string{"faux", "f", "non", "n", "false", "0", ""},
+}
+
+// Spanish numbers (1.234,56) and words.
+var LocaleSpanish = Locale{
+	Name:     "es",
+	Decimal:  ",",
+	Grouping: ".",
+	Percent:  true,
+	Trues:    []string{"verdadero", "v", "sí", "si", "s", "true", "1"},
+	Falses:   []string{"falso", "f", "no", "n", "false", "0", ""},
+}
+
+// The value separator used by locales whose numbers contain commas or spaces.
+var localeValueSeparator = regexp.MustCompile(`\s*[;|]\s*`)
+
+// Returns a copy of this decoder which reads and writes numbers and booleans
+// in the given locale. If the locale writes numbers with commas or spaces the
+// multi-value types are changed to be separated by semicolons.
+func (d Decoder) WithLocale(l Locale) Decoder {
+	localized := d.Clone()
+	if l.Decimal == "," || l.Grouping == "," || strings.TrimSpace(l.Grouping) == "" && l.Grouping != "" {
+		for _, m := range localized.multis() {
+			m.ValueSeparator = localeValueSeparator
+			m.ValueDelimiter = "; "
+		}
+	}
+	localized.Locale = &l
+	localized.Int = l.ParseInt
+	localized.Uint = l.ParseUint
+	localized.Float = l.ParseFloat
+	localized.Trues = wordSet(l.Trues)
+	localized.Falses = wordSet(l.Falses)
+	return localized
+}
+
+// Parses an integer written in this locale.
+func (l Locale) ParseInt(s string, bits int) (int64, error) {
+	n, percent := l.normalize(s)
+	if percent {
+		return 0, fmt.Errorf("percent not supported for integer '%s'", s)
+	}
+	return strconv.ParseInt(n, 10, bits)
+}
+
+// Parses an unsigned integer written in this locale.
+func (l Locale) ParseUint(s string, bits int) (uint64, error) {
+	n, percent := l.normalize(s)
+	if percent {
+		return 0, fmt.Errorf("percent not supported for integer '%s'", s)
+	}
+	return strconv.ParseUint(n, 10, bits)
+}
+
+// Parses a float written in this locale.
+func (l Locale) ParseFloat(s string, bits int) (float64, error) {
+	n, percent := l.normalize(s)
+	f, err := strconv.ParseFloat(n, bits)
+	if err != nil {
+		return f, err
+	}
+	if percent {
+		f /= 100
+	}
+	return f, nil
+}
+
+// Writes an integer in this locale.
+func (l Locale) FormatInt(i int64) string {
+	return l.group(strconv.FormatInt(i, 10))
+}
+
+// Writes an unsigned integer in this locale.
+func (l Locale) FormatUint(u uint64) string {
+	return l.group(strconv.FormatUint(u, 10))
+}
+
+// Writes a float in this locale.
+func (l Locale) FormatFloat(f float64, bits int) string {
+	s := strconv.FormatFloat(f, 'f', -1, bits)
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	s = l.group(whole)
+	if hasFraction {
+		s += l.Decimal + fraction
+	}
+	return s
+}
+
+// Writes a boolean in this locale.
+func (l Locale) FormatBool(b bool) string {
+	if b && len(l.Trues) > 0 {
+		return l.Trues[0]
+	}
+	if !b && len(l.Falses) > 0 {
+		return l.Falses[0]
+	}
+	return strconv.FormatBool(b)
+}
+
+// Converts a number in this locale to the format strconv expects and
+// returns whether it was a percentage.
+func (l Locale) normalize(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	percent := false
+	if l.Percent && strings.HasSuffix(s, "%") {
+		percent = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	}
+	if l.Grouping != "" {
+		s = strings.ReplaceAll(s, l.Grouping, "")
+		if strings.TrimSpace(l.Grouping) == "" {
+			s = strings.ReplaceAll(s, "\u00a0", "")
+			s = strings.ReplaceAll(s, "\u202f", "")
+		}
+	}
+	if l.Decimal != "" && l.Decimal != "." {
+		s = strings.Replace(s, l.Decimal, ".", 1)
+	}
+	return s, percent
+}
+
+// Inserts the grouping separator between every three digits of the given
+// integer string.
+func (l Locale) group(digits string) string {
+	if l.Grouping == "" {
+		return digits
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if len(digits) <= 3 {
+		return sign + digits
+	}
+	sb := strings.Builder{}
+	sb.WriteString(sign)
+	first := len(digits) % 3
+	if first > 0 {
+		sb.WriteString(digits[:first])
+	}
+	for i := first; i < len(digits); i += 3 {
+		if i > 0 {
+			sb.WriteString(l.Grouping)
+		}
+		sb.WriteString(digits[i : i+3])
+	}
+	return sb.String()
+}
+
+// Converts the given words to a set of lowercase words.
+func wordSet(words []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[strings.ToLower(word)] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,108 @@
+package refstr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	german := NewDecoder().WithLocale(LocaleGerman)
+	french := NewDecoder().WithLocale(LocaleFrench)
+	english := NewDecoder().WithLocale(LocaleEnglish)
+
+	tests := []struct {
+		name     string
+		decoder  Decoder
+		typ      reflect.Type
+		decode   string
+		expected any
+		encoded  string
+		err      bool
+	}{{
+		name:     "german float",
+		decoder:  german,
+		typ:      TypeOf[float64](),
+		decode:   "1.234,56",
+		expected: float64(1234.56),
+		encoded:  "1.234,56",
+	}, {
+		name:     "german percent",
+		decoder:  german,
+		typ:      TypeOf[float64](),
+		decode:   "12,5 %",
+		expected: float64(0.125),
+		encoded:  "0,125",
+	}, {
+		name:     "german int",
+		decoder:  german,
+		typ:      TypeOf[int](),
+		decode:   "-1.000.000",
+		expected: int(-1000000),
+		encoded:  "-1.000.000",
+	}, {
+		name:    "german int percent",
+		decoder: german,
+		typ:     TypeOf[int](),
+		decode:  "5%",
+		err:     true,
+	}, {
+		name:     "german bool",
+		decoder:  german,
+		typ:      TypeOf[bool](),
+		decode:   "Ja",
+		expected: true,
+		encoded:  "wahr",
+	}, {
+		name:     "german slice",
+		decoder:  german,
+		typ:      TypeOf[[]float32](),
+		decode:   "[1,5; 2,25]",
+		expected: []float32{1.5, 2.25},
+		encoded:  "[1,5; 2,25]",
+	}, {
+		name:     "french float",
+		decoder:  french,
+		typ:      TypeOf[float64](),
+		decode:   "1 234 567,8",
+		expected: float64(1234567.8),
+		encoded:  "1 234 567,8",
+	}, {
+		name:     "french bool",
+		decoder:  french,
+		typ:      TypeOf[bool](),
+		decode:   "non",
+		expected: false,
+		encoded:  "faux",
+	}, {
+		name:     "english uint",
+		decoder:  english,
+		typ:      TypeOf[uint](),
+		decode:   "12,345",
+		expected: uint(12345),
+		encoded:  "12,345",
+	}, {
+		name:    "english bool",
+		decoder: english,
+		typ:     TypeOf[bool](),
+		decode:  "ja",
+		err:     true,
+	}}
+
+	for _, test := range tests {
+		val, err := test.decoder.DecodeType(test.typ, test.decode)
+		if (err != nil) != test.err {
+			t.Errorf("[%s] Unexpected error result during DecodeType: %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if !StringEqual(val, test.expected) {
+			t.Errorf("[%s] Expected %+v but got %+v", test.name, test.expected, val)
+		}
+		encoded, err := test.decoder.Encode(val)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("[%s] Expected encoded %s but got %s (%v)", test.name, test.encoded, encoded, err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package refstr
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Get and Set are not supported on paths with wildcards or recursive descent,
+// GetAll and SetAll are used instead.
+var ErrPathPattern = errors.New("path has wildcards, expand it first")
+
+// A path with filters was gotten or set but the filters didn't match exactly
+// one value.
+var ErrFilterMatches = errors.New("path filters must match exactly one value")
+
+// A concrete path matched by a path with wildcards and its value.
+type Match struct {
+	Path  Path
+	Value reflect.Value
+	Err   error
+}
+
+// The matches which failed when getting or setting all matches of a path.
+type MatchErrors []Match
+
+// Returns a summary of the failed matches.
+func (e MatchErrors) Error() string {
+	failures := make([]string, len(e))
+	for i, m := range e {
+		failures[i] = fmt.Sprintf("%s: %v", m.Path, m.Err)
+	}
+	return fmt.Sprintf("%d matches failed: %s", len(e), strings.Join(failures, "; "))
+}
+
+// Returns whether any of the failed matches is the target error.
+func (e MatchErrors) Is(target error) bool {
+	for _, m := range e {
+		if errors.Is(m.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether this path has wildcards or recursive descent and needs
+// to be expanded against a value.
+func (p Path) IsPattern() bool {
+	for _, node := range p.nodes {
+		if node.IsPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the concrete path matched by a path whose only patterns are filters
+// which match exactly one value on the given value.
+func (p Path) resolveFilters(root any) (Path, error) {
+	for _, node := range p.nodes {
+		if node.IsPattern() && node.Kind != NodeFilter {
+			return p, ErrPathPattern
+		}
+	}
+	paths := p.Expand(root)
+	if len(paths) != 1 {
+		return p, fmt.Errorf("%w: %s matched %d", ErrFilterMatches, p, len(paths))
+	}
+	return paths[0], nil
+}
+
+// Returns the concrete paths this path matches on the given value. A path
+// without wildcards or recursive descent matches itself.
+func (p Path) Expand(root any) []Path {
+	type expanding struct {
+		path  Path
+		value reflect.Value
+	}
+
+	current := []expanding{{path: NewPath(p.root), value: Reflect(root)}}
+	for _, node := range p.nodes {
+		next := make([]expanding, 0, len(current))
+		for _, e := range current {
+			if !node.IsPattern() {
+				next = append(next, expanding{path: e.path.append(node), value: getNode(node, e.value)})
+				continue
+			}
+			for _, match := range node.Expand(node, e.value) {
+				matched := expanding{path: e.path, value: e.value}
+				for _, m := range match {
+					matched.path = matched.path.append(m)
+					matched.value = getNode(m, matched.value)
+				}
+				next = append(next, matched)
+			}
+		}
+		current = next
+	}
+
+	paths := make([]Path, len(current))
+	for i, e := range current {
+		paths[i] = e.path
+	}
+	return paths
+}
+
+// Gets the value of every match of this path on the given value. Matches that
+// could not be gotten have an error and are returned as MatchErrors as well.
+func (p Path) GetAll(root any) ([]Match, error) {
+	paths := p.Expand(root)
+	matches := make([]Match, len(paths))
+	failed := make(MatchErrors, 0)
+	for i, path := range paths {
+		value, err := path.Get(root)
+		matches[i] = Match{Path: path, Value: value, Err: err}
+		if err != nil {
+			failed = append(failed, matches[i])
+		}
+	}
+	if len(failed) > 0 {
+		return matches, failed
+	}
+	return matches, nil
+}
+
+// Sets the value of every match of this path on the given value. Matches that
+// could not be set have an error and are returned as MatchErrors as well.
+func (p Path) SetAll(root any, val any) ([]Match, error) {
+	return setAll(p.Expand(root), val, func(path Path) error {
+		return path.Set(root, val)
+	})
+}
+
+// Sets the value of each path with the set func, returning the matches and
+// the ones that failed as MatchErrors.
+func setAll(paths []Path, val any, set func(path Path) error) ([]Match, error) {
+	matches := make([]Match, len(paths))
+	failed := make(MatchErrors, 0)
+	for i, path := range paths {
+		err := set(path)
+		matches[i] = Match{Path: path, Value: Reflect(val), Err: err}
+		if err != nil {
+			failed = append(failed, matches[i])
+		}
+	}
+	if len(failed) > 0 {
+		return matches, failed
+	}
+	return matches, nil
+}
+
+// Gets every value the reference matches.
+func (r Ref) GetAll() ([]Match, error) {
+	if r.store != nil {
+		return r.store.values(r.path)
+	}
+	return r.path.GetAll(r.root)
+}
+
+// Sets every value the reference matches.
+func (r Ref) SetAll(value any) ([]Match, error) {
+	if r.store != nil {
+		return setAll(r.store.expand(r.path), value, func(path Path) error {
+			return r.store.write(func() (*Change, error) {
+				return r.store.set(path, value, nil)
+			})
+		})
+	}
+	return r.path.SetAll(r.root, value)
+}
+
+// Returns a node which matches every field, key or index of a value of the
+// given type.
+func wildcardNode(rt reflect.Type) Node {
+	node := Node{
+		Key:       "*",
+		KeyString: "*",
+		Kind:      NodeWildcard,
+		Expand:    wildcardExpand,
+	}
+	if rt != nil {
+		switch c := ConcreteType(rt); c.Kind() {
+		case reflect.Map:
+			node.KeyType = c.Key()
+			node.Type = c.Elem()
+		case reflect.Slice, reflect.Array:
+			node.KeyType = indexType
+			node.Type = c.Elem()
+		}
+	}
+	return node
+}
+
+// Returns a node which matches a value and everything inside of it.
+func descendantNode() Node {
+	return Node{
+		Key:       "..",
+		KeyString: "..",
+		Kind:      NodeDescendant,
+		Expand:    descendantExpand,
+	}
+}
+
+var wildcardExpand NodeExpand = func(n Node, rv reflect.Value) [][]Node {
+	children := childNodes(rv)
+	matches := make([][]Node, len(children))
+	for i, child := range children {
+		matches[i] = []Node{child}
+	}
+	return matches
+}
+
+var descendantExpand NodeExpand = func(n Node, rv reflect.Value) [][]Node {
+	matches := [][]Node{{}}
+	visiting := make(map[reference]bool)
+
+	var descend func(rv reflect.Value, prefix []Node)
+	descend = func(rv reflect.Value, prefix []Node) {
+		refs := references(rv)
+		for _, ref := range refs {
+			visiting[ref] = true
+		}
+		for _, child := range childNodes(rv) {
+			value := getNode(child, rv)
+			if isVisiting(value, visiting) {
+				continue
+			}
+			path := append(prefix[:len(prefix):len(prefix)], child)
+			matches = append(matches, path)
+			descend(value, path)
+		}
+		for _, ref := range refs {
+			delete(visiting, ref)
+		}
+	}
+	descend(rv, nil)
+
+	return matches
+}
+
+// A pointer or map which could be visited again through a cycle.
+type reference struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// Returns the pointers and maps the value is referenced through.
+func references(rv reflect.Value) []reference {
+	refs := make([]reference, 0)
+	for inner := rv; inner.IsValid(); inner = inner.Elem() {
+		if (inner.Kind() == reflect.Pointer || inner.Kind() == reflect.Map) && !inner.IsNil() {
+			refs = append(refs, reference{typ: inner.Type(), ptr: inner.Pointer()})
+		}
+		if !IsPointing(inner) {
+			break
+		}
+	}
+	return refs
+}
+
+// Calls fn while the pointers and maps the value is referenced through are
+// marked as visiting so cycles are not followed.
+func visit(visiting map[reference]bool, rv reflect.Value, fn func()) {
+	refs := references(rv)
+	for _, ref := range refs {
+		visiting[ref] = true
+	}
+	fn()
+	for _, ref := range refs {
+		delete(visiting, ref)
+	}
+}
+
+// Returns whether the value is already being visited.
+func isVisiting(rv reflect.Value, visiting map[reference]bool) bool {
+	for _, ref := range references(rv) {
+		if visiting[ref] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the existing fields, keys and indices of the value which can be
+// matched by wildcards. Methods and unexported fields are not matched.
+func childNodes(rv reflect.Value) []Node {
+	return children(rv, false)
+}
+
+// Returns the existing fields, keys and indices of the value and its getter
+// methods if methods is true. Unexported fields are skipped and map keys are
+// sorted.
+func children(rv reflect.Value, methods bool) []Node {
+	if !rv.IsValid() || !Concrete(rv).IsValid() {
+		return nil
+	}
+	nodes := GetValueNodes(rv)
+	children := make([]Node, 0, len(nodes.InOrder))
+	for _, node := range nodes.InOrder {
+		if node.IsDynamic() || node.IsWriteOnly() || (node.Kind == NodeMethod && !methods) {
+			continue
+		}
+		if node.Kind == NodeField && !token.IsExported(node.KeyString) {
+			continue
+		}
+		children = append(children, node)
+	}
+	if Concrete(rv).Kind() == reflect.Map {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].KeyString < children[j].KeyString
+		})
+	}
+	return children
+}
+
+// Returns whether the node exists on the given value.
+func hasNode(node Node, rv reflect.Value) bool {
+	return getNode(node, rv).IsValid()
+}
+
+// Gets the node from the value, returning an invalid value if the value or the
+// node does not exist instead of panicking.
+func getNode(node Node, rv reflect.Value) reflect.Value {
+	if node.Get == nil || !rv.IsValid() {
+		return invalidValue
+	}
+	if !Concrete(rv).IsValid() {
+		return invalidValue
+	}
+	return node.Get(node, rv)
+}
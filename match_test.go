@@ -0,0 +1,181 @@
+package refstr
+
+import (
+	"errors"
+	"testing"
+)
+
+type matchServer struct {
+	Name    string
+	Port    int
+	Enabled bool
+}
+
+type matchLimits struct{ CPU, Memory int }
+
+type matchCluster struct {
+	Enabled bool
+	Servers []matchServer
+	Limits  matchLimits
+	Ports   map[string]int
+	Backup  *matchServer
+	Extra   any
+	Points  []point
+}
+
+type matchLoop struct {
+	Name string
+	Next *matchLoop
+}
+
+func newMatchCluster() *matchCluster {
+	return &matchCluster{
+		Servers: []matchServer{{Name: "a", Port: 1}, {Name: "b", Port: 2, Enabled: true}},
+		Limits:  matchLimits{CPU: 2, Memory: 512},
+		Ports:   map[string]int{"http": 80, "https": 443},
+		Extra:   matchServer{Name: "extra", Port: 3},
+		Points:  []point{{X: 1, Y: 2}},
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		formatted string
+		matches   []string
+		values    []any
+	}{{
+		name:    "slice wildcard",
+		path:    "Servers[*].Port",
+		matches: []string{"Servers[0].Port", "Servers[1].Port"},
+		values:  []any{1, 2},
+	}, {
+		name:    "struct wildcard",
+		path:    "Limits.*",
+		matches: []string{"Limits.CPU", "Limits.Memory"},
+		values:  []any{2, 512},
+	}, {
+		name:      "map wildcard",
+		path:      "Ports.*",
+		formatted: "Ports[*]",
+		matches:   []string{"Ports[http]", "Ports[https]"},
+		values:    []any{80, 443},
+	}, {
+		name:    "descendant",
+		path:    "..Enabled",
+		matches: []string{"Enabled", "Servers[0].Enabled", "Servers[1].Enabled", "Backup.Enabled", "Extra.Enabled"},
+		values:  []any{false, false, true, false, false},
+	}, {
+		name:    "descendant under field",
+		path:    "Servers..Name",
+		matches: []string{"Servers[0].Name", "Servers[1].Name"},
+		values:  []any{"a", "b"},
+	}, {
+		name:    "lookup after wildcard",
+		path:    "*.Port",
+		matches: []string{"Backup.Port", "Extra.Port"},
+		values:  []any{0, 3},
+	}, {
+		name:    "lookup in interface",
+		path:    "Extra.Name",
+		matches: []string{"Extra.Name"},
+		values:  []any{"extra"},
+	}, {
+		name:    "no wildcards",
+		path:    "Limits.CPU",
+		matches: []string{"Limits.CPU"},
+		values:  []any{2},
+	}}
+
+	for _, test := range tests {
+		cluster := newMatchCluster()
+		cluster.Backup = &matchServer{}
+		ref, err := NewRef(cluster).NextPath(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		formatted := test.formatted
+		if formatted == "" {
+			formatted = test.path
+		}
+		if ref.Path().String() != formatted {
+			t.Errorf("[%s] expected path %s but got %s", test.name, formatted, ref.Path().String())
+		}
+		matches, err := ref.GetAll()
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		paths := make([]string, len(matches))
+		values := make([]any, len(matches))
+		for i, m := range matches {
+			paths[i] = m.Path.String()
+			values[i] = m.Value.Interface()
+		}
+		if !StringEqual(paths, test.matches) {
+			t.Errorf("[%s] expected matches %v but got %v", test.name, test.matches, paths)
+		}
+		if !StringEqual(values, test.values) {
+			t.Errorf("[%s] expected values %v but got %v", test.name, test.values, values)
+		}
+	}
+}
+
+func TestSetAll(t *testing.T) {
+	cluster := newMatchCluster()
+	ref, _ := NewRef(cluster).NextPath("Servers[*].Port")
+	matches, err := ref.SetAll(8080)
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("unexpected result %v %v", matches, err)
+	}
+	if cluster.Servers[0].Port != 8080 || cluster.Servers[1].Port != 8080 {
+		t.Errorf("expected all ports to be set but got %v", cluster.Servers)
+	}
+
+	ref, _ = NewRef(cluster).NextPath("..Enabled")
+	matches, err = ref.SetAll(true)
+	var failed MatchErrors
+	if !errors.As(err, &failed) || len(failed) != 1 || failed[0].Path.String() != "Extra.Enabled" || len(matches) != 4 {
+		t.Errorf("expected the value in the interface to fail but got %v", err)
+	}
+	if !cluster.Enabled || !cluster.Servers[0].Enabled || !cluster.Servers[1].Enabled {
+		t.Errorf("expected all enabled to be set but got %+v", cluster)
+	}
+
+	ref, _ = NewRef(cluster).NextPath("Points[*].Sum")
+	matches, err = ref.SetAll(3)
+	if !errors.Is(err, ErrSetNotSupported) {
+		t.Errorf("expected set not supported but got %v", err)
+	}
+	if !errors.As(err, &failed) || len(failed) != 1 || failed[0].Path.String() != "Points[0].Sum" || matches[0].Err == nil {
+		t.Errorf("expected failed match for Points[0].Sum but got %v", err)
+	}
+
+	if _, err := ref.Get(); !errors.Is(err, ErrPathPattern) {
+		t.Errorf("expected pattern error from Get but got %v", err)
+	}
+	if err := ref.Set(3); !errors.Is(err, ErrPathPattern) {
+		t.Errorf("expected pattern error from Set but got %v", err)
+	}
+}
+
+func TestExpandCycle(t *testing.T) {
+	loop := &matchLoop{Name: "a"}
+	loop.Next = &matchLoop{Name: "b", Next: loop}
+
+	path, err := ParsePath(TypeOf[*matchLoop](), "..Name")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	paths := path.Expand(loop)
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = p.String()
+	}
+	expected := []string{"Name", "Next.Name"}
+	if !StringEqual(names, expected) {
+		t.Errorf("expected %v but got %v", expected, names)
+	}
+}
@@ -3,6 +3,8 @@ package refstr
 import (
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
 // The get func for a node if supported
@@ -11,6 +13,42 @@ type NodeGet = func(n Node, rv reflect.Value) reflect.Value
 // The set func for a node if supported
 type NodeSet = func(n Node, rv reflect.Value, val reflect.Value) error
 
+// The kind of value a node references.
+type NodeKind int
+
+const (
+	// A node given by SetNodes or SetTypeNodes.
+	NodeCustom NodeKind = iota
+	// A struct field.
+	NodeField
+	// A getter or setter method.
+	NodeMethod
+	// A map key.
+	NodeMapKey
+	// A slice or array index, negative indices are from the end.
+	NodeIndex
+	// The position after the last element of a slice which appends when set.
+	NodeAppend
+	// A range of slice or array elements.
+	NodeRange
+	// Every field, map key or index of a value.
+	NodeWildcard
+	// A value and everything inside of it at any depth.
+	NodeDescendant
+	// The fields, keys or indices of a value which pass a filter.
+	NodeFilter
+	// A name or key that's looked up on the value, used when the type at that
+	// point in the path isn't known until the value is.
+	NodeLookup
+)
+
+// The delete func for a node if supported
+type NodeDelete = func(n Node, rv reflect.Value) error
+
+// The expand func for a node which matches other nodes on a value. Each
+// match is the sequence of nodes from the value to the matched value.
+type NodeExpand = func(n Node, rv reflect.Value) [][]Node
+
 // A node is a part of a path that represents a struct field, map key,
 // slice or array index, a function call, or a method on a struct.
 // This is returned when inspecting the available nodes for a type or value.
@@ -19,9 +57,13 @@ type Node struct {
 	Key       any
 	KeyType   reflect.Type
 	Type      reflect.Type
+	Kind      NodeKind
 	CopyOnly  bool
 	Get       NodeGet
 	Set       NodeSet
+	Delete    NodeDelete
+	Insert    NodeSet
+	Expand    NodeExpand
 }
 
 // Returns whether this node represents a dynamic node and not a concrete one.
@@ -30,6 +72,12 @@ func (n Node) IsDynamic() bool {
 	return n.Key == nil && n.KeyString == ""
 }
 
+// Returns whether this node matches other nodes on a value and can only be
+// used to expand a path.
+func (n Node) IsPattern() bool {
+	return n.Expand != nil
+}
+
 // Returns if the node is ready only
 func (n Node) IsReadOnly() bool {
 	return n.Set == nil
@@ -110,49 +158,117 @@ func (nodes Nodes) Clone() *Nodes {
 	return NewNodes(nodes.InOrder)
 }
 
-var typeNodes map[reflect.Type]*Nodes = make(map[reflect.Type]*Nodes)
+// A registry of nodes by type which is safe for concurrent use. Reads are
+// lock-free, writes copy the registry and swap it in.
+type nodeRegistry struct {
+	nodes atomic.Pointer[map[reflect.Type]*Nodes]
+	lock  sync.Mutex
+}
+
+// Returns the nodes registered for the given type.
+func (r *nodeRegistry) get(rt reflect.Type) (*Nodes, bool) {
+	current := r.nodes.Load()
+	if current == nil {
+		return nil, false
+	}
+	nodes, ok := (*current)[rt]
+	return nodes, ok
+}
+
+// Adds the given nodes to the registry. The lock must be held.
+func (r *nodeRegistry) publish(entries map[reflect.Type]*Nodes) {
+	if len(entries) == 0 {
+		return
+	}
+	next := make(map[reflect.Type]*Nodes)
+	if current := r.nodes.Load(); current != nil {
+		for t, n := range *current {
+			next[t] = n
+		}
+	}
+	for t, n := range entries {
+		next[t] = n
+	}
+	r.nodes.Store(&next)
+}
+
+var typeNodes nodeRegistry
 
 // Sets the nodes available for the given value type.
 func SetNodes[V any](nodes []Node) {
-	typeNodes[TypeOf[V]()] = NewNodes(nodes)
+	SetTypeNodes(TypeOf[V](), nodes)
 }
 
 // Sets the nodes available for the given type.
 func SetTypeNodes(rt reflect.Type, nodes []Node) {
-	typeNodes[rt] = NewNodes(nodes)
+	typeNodes.lock.Lock()
+	defer typeNodes.lock.Unlock()
+
+	typeNodes.publish(map[reflect.Type]*Nodes{rt: NewNodes(nodes)})
 }
 
 // Inspects a given type and returns the available nodes. Inspecting a value
 // is more accurate since some types have dynamic values (slices & maps).
+// The returned nodes are shared and should not be modified.
 func GetTypeNodes(rt reflect.Type) *Nodes {
-	if nodes, ok := typeNodes[rt]; ok {
+	if nodes, ok := typeNodes.get(rt); ok {
+		return nodes
+	}
+
+	typeNodes.lock.Lock()
+	defer typeNodes.lock.Unlock()
+
+	pending := make(map[reflect.Type]*Nodes)
+	nodes := buildTypeNodes(rt, pending)
+	typeNodes.publish(pending)
+
+	return nodes
+}
+
+// Returns the nodes for the given type from the pending nodes or the registry.
+func lookupTypeNodes(rt reflect.Type, pending map[reflect.Type]*Nodes) (*Nodes, bool) {
+	if nodes, ok := pending[rt]; ok {
+		return nodes, true
+	}
+	return typeNodes.get(rt)
+}
+
+// Builds the nodes for the given type, adding any nodes built for the type
+// and the types it depends on to pending. The registry lock must be held.
+func buildTypeNodes(rt reflect.Type, pending map[reflect.Type]*Nodes) *Nodes {
+	if nodes, ok := lookupTypeNodes(rt, pending); ok {
 		return nodes
 	}
 
 	c := ConcreteType(rt)
 
-	if nodes, ok := typeNodes[c]; ok {
+	if nodes, ok := lookupTypeNodes(c, pending); ok {
 		return nodes
 	}
 
 	nodes := NewNodes(nil)
-	typeNodes[c] = nodes
+	pending[c] = nodes
 
 	switch c.Kind() {
 	case reflect.Map:
 		nodes.Add(Node{
 			KeyType:  c.Key(),
 			Type:     c.Elem(),
+			Kind:     NodeMapKey,
 			CopyOnly: true,
 			Get:      mapGet,
 			Set:      mapSet,
+			Delete:   mapDelete,
 		})
 	case reflect.Slice:
 		nodes.Add(Node{
 			KeyType: indexType,
 			Type:    c.Elem(),
+			Kind:    NodeIndex,
 			Get:     indexGet,
 			Set:     indexSet,
+			Delete:  indexDelete,
+			Insert:  indexInsert,
 		})
 	case reflect.Array:
 		len := c.Len()
@@ -163,13 +279,14 @@ func GetTypeNodes(rt reflect.Type) *Nodes {
 				KeyType:   indexType,
 				KeyString: strconv.Itoa(i),
 				Type:      elementType,
+				Kind:      NodeIndex,
 				Get:       indexGet,
 				Set:       indexSet,
 			})
 		}
 	case reflect.Func:
 		if IsGetter(c, nil) {
-			returnNodes := GetTypeNodes(c.Out(0))
+			returnNodes := buildTypeNodes(c.Out(0), pending)
 			for _, rn := range returnNodes.InOrder {
 				node := rn
 				get := node.Get
@@ -177,6 +294,7 @@ func GetTypeNodes(rt reflect.Type) *Nodes {
 					return get(n, rv.Call([]reflect.Value{})[0])
 				}
 				node.Set = nil
+				node.Delete = nil
 				nodes.Add(node)
 			}
 		}
@@ -185,7 +303,7 @@ func GetTypeNodes(rt reflect.Type) *Nodes {
 		for i := 0; i < fields; i++ {
 			field := c.Field(i)
 			if field.Anonymous {
-				embeddedNodes := GetTypeNodes(field.Type)
+				embeddedNodes := buildTypeNodes(field.Type, pending)
 				for _, n := range embeddedNodes.InOrder {
 					nodes.Add(n)
 				}
@@ -195,8 +313,10 @@ func GetTypeNodes(rt reflect.Type) *Nodes {
 					KeyType:   fieldType,
 					KeyString: field.Name,
 					Type:      field.Type,
+					Kind:      NodeField,
 					Get:       getFieldGet(i),
 					Set:       getFieldSet(i),
+					Delete:    zeroDelete,
 				})
 			}
 		}
@@ -205,14 +325,14 @@ func GetTypeNodes(rt reflect.Type) *Nodes {
 	p := reflect.PointerTo(c)
 	if p.NumMethod() != c.NumMethod() {
 		pnodes := nodes.Clone()
-		typeNodes[p] = pnodes
+		pending[p] = pnodes
 
 		addMethodNodes(p, pnodes)
 	}
 
 	addMethodNodes(c, nodes)
 
-	if exactNodes, ok := typeNodes[rt]; ok {
+	if exactNodes, ok := lookupTypeNodes(rt, pending); ok {
 		return exactNodes
 	}
 
@@ -230,6 +350,7 @@ func addMethodNodes(t reflect.Type, nodes *Nodes) {
 				KeyType:   fieldType,
 				KeyString: method.Name,
 				Type:      method.Type.Out(0),
+				Kind:      NodeMethod,
 				Get:       getMethodGet(i),
 			})
 		} else if IsSetter(method.Type, t) {
@@ -238,6 +359,7 @@ func addMethodNodes(t reflect.Type, nodes *Nodes) {
 				KeyType:   fieldType,
 				KeyString: method.Name,
 				Type:      method.Type.In(1),
+				Kind:      NodeMethod,
 				Set:       getMethodSet(i),
 			})
 		}
@@ -267,9 +389,11 @@ func GetValueNodes(v any) *Nodes {
 				KeyString: ToString(key),
 				KeyType:   mapKeyType,
 				Type:      mapValue,
+				Kind:      NodeMapKey,
 				CopyOnly:  true,
 				Get:       mapGet,
 				Set:       mapSet,
+				Delete:    mapDelete,
 			})
 		}
 
@@ -288,8 +412,11 @@ func GetValueNodes(v any) *Nodes {
 				KeyType:   indexType,
 				KeyString: strconv.Itoa(i),
 				Type:      elemType,
+				Kind:      NodeIndex,
 				Get:       indexGet,
 				Set:       indexSet,
+				Delete:    indexDelete,
+				Insert:    indexInsert,
 			})
 		}
 
@@ -0,0 +1,59 @@
+package refstr
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type nodeLeaf struct{ Value int }
+
+func (l nodeLeaf) Double() int { return l.Value * 2 }
+
+type nodeBranch struct {
+	Leaves map[string]nodeLeaf
+	List   []*nodeLeaf
+	Self   *nodeBranch
+}
+
+func TestGetTypeNodesConcurrent(t *testing.T) {
+	types := []reflect.Type{
+		TypeOf[nodeLeaf](),
+		TypeOf[*nodeLeaf](),
+		TypeOf[nodeBranch](),
+		TypeOf[*nodeBranch](),
+		TypeOf[map[string]nodeBranch](),
+		TypeOf[[]nodeBranch](),
+		TypeOf[[3]nodeLeaf](),
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				for _, rt := range types {
+					if GetTypeNodes(rt) == nil {
+						t.Errorf("expected nodes for %v", rt)
+					}
+				}
+				branch := &nodeBranch{Leaves: map[string]nodeLeaf{"a": {Value: k}}}
+				ref := NewRef(branch).Nexts([]any{"Leaves", "b", "Value"})
+				if err := ref.Set(k); err != nil {
+					t.Errorf("unexpected set error: %v", err)
+				}
+				double, err := NewRef(branch).Nexts([]any{"Leaves", "a", "Double"}).Get()
+				if err != nil || double.Interface() != k*2 {
+					t.Errorf("unexpected get %v %v", double, err)
+				}
+				GetValueNodes(branch)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !StringEqual(GetTypeNodes(TypeOf[nodeBranch]()).KeyStrings(), []string{"Leaves", "List", "Self"}) {
+		t.Errorf("unexpected nodes %v", GetTypeNodes(TypeOf[nodeBranch]()).KeyStrings())
+	}
+}
@@ -0,0 +1,157 @@
+package refstr
+
+import (
+	"reflect"
+	"regexp"
+)
+
+// An option which changes the behavior of a decoder as it's built.
+type DecoderOption func(d *Decoder)
+
+// Returns a copy of this decoder with the given options applied. The copy
+// shares nothing with this decoder so neither is affected by changes to the other.
+func (d Decoder) With(opts ...DecoderOption) Decoder {
+	child := d.Clone()
+	for _, opt := range opts {
+		opt(&child)
+	}
+	return child
+}
+
+// Sets how slices are parsed.
+func WithSlice(m Multi) DecoderOption {
+	return func(d *Decoder) { d.Slice = m }
+}
+
+// Sets how arrays are parsed.
+func WithArray(m Multi) DecoderOption {
+	return func(d *Decoder) { d.Array = m }
+}
+
+// Sets how maps are parsed.
+func WithMap(m Multi) DecoderOption {
+	return func(d *Decoder) { d.Map = m }
+}
+
+// Sets how structs are parsed.
+func WithStruct(m Multi) DecoderOption {
+	return func(d *Decoder) { d.Struct = m }
+}
+
+// Sets how values of slices, arrays, maps and structs are separated when
+// parsing and the delimiter placed between them when encoding.
+func WithValueSeparator(separator *regexp.Regexp, delimiter string) DecoderOption {
+	return func(d *Decoder) {
+		for _, m := range d.multis() {
+			m.ValueSeparator = separator
+			m.ValueDelimiter = delimiter
+		}
+	}
+}
+
+// Sets how keys and values of maps and structs are separated when parsing and
+// the delimiter placed between them when encoding.
+func WithKeySeparator(separator *regexp.Regexp, delimiter string) DecoderOption {
+	return func(d *Decoder) {
+		d.Map.KeySeparator, d.Map.KeyDelimiter = separator, delimiter
+		d.Struct.KeySeparator, d.Struct.KeyDelimiter = separator, delimiter
+	}
+}
+
+// Sets whether slices, arrays, maps and structs must start and end with their delimiters.
+func WithStrict(strict bool) DecoderOption {
+	return func(d *Decoder) {
+		for _, m := range d.multis() {
+			m.Strict = strict
+		}
+	}
+}
+
+// Sets the words accepted as true, replacing the existing words.
+func WithTrues(words ...string) DecoderOption {
+	return func(d *Decoder) { d.Trues = wordSet(words) }
+}
+
+// Sets the words accepted as false, replacing the existing words.
+func WithFalses(words ...string) DecoderOption {
+	return func(d *Decoder) { d.Falses = wordSet(words) }
+}
+
+// Sets how signed integers are parsed.
+func WithInt(parse func(string, int) (int64, error)) DecoderOption {
+	return func(d *Decoder) { d.Int = parse }
+}
+
+// Sets how unsigned integers are parsed.
+func WithUint(parse func(string, int) (uint64, error)) DecoderOption {
+	return func(d *Decoder) { d.Uint = parse }
+}
+
+// Sets how floats are parsed.
+func WithFloat(parse func(string, int) (float64, error)) DecoderOption {
+	return func(d *Decoder) { d.Float = parse }
+}
+
+// Sets how complex numbers are parsed.
+func WithComplex(parse func(string, int) (complex128, error)) DecoderOption {
+	return func(d *Decoder) { d.Complex = parse }
+}
+
+// Adds a custom parser for the given type.
+func WithParser(rt reflect.Type, parser Parser) DecoderOption {
+	return func(d *Decoder) {
+		d.Parsers[rt] = parser
+	}
+}
+
+// Replaces the custom parsers with a copy of the given parsers.
+func WithParsers(parsers map[reflect.Type]Parser) DecoderOption {
+	return func(d *Decoder) {
+		d.Parsers = make(map[reflect.Type]Parser, len(parsers))
+		for t, p := range parsers {
+			d.Parsers[t] = p
+		}
+	}
+}
+
+// Adds the named values of an enum type. If a value can't be converted to the
+// type, decoding and encoding the type returns an ErrInvalidEnum.
+func WithEnum(rt reflect.Type, values ...EnumValue) DecoderOption {
+	return func(d *Decoder) {
+		d.Enums[rt] = values
+	}
+}
+
+// Adds a description of the syntax a custom parser accepts for a type.
+func WithHint(rt reflect.Type, hint string) DecoderOption {
+	return func(d *Decoder) { d.Hints[rt] = hint }
+}
+
+// Reads and writes numbers and booleans in the given locale.
+func WithLocale(l Locale) DecoderOption {
+	return func(d *Decoder) { *d = d.WithLocale(l) }
+}
+
+// Sets how byte slices and arrays are read and written.
+func WithBytes(encoding BytesEncoding) DecoderOption {
+	return func(d *Decoder) { d.Bytes = encoding }
+}
+
+// Allows integer slice elements to be ranges which expand to at most max
+// elements in a single slice (0 for no limit).
+func WithRanges(max int) DecoderOption {
+	return func(d *Decoder) {
+		d.Ranges = true
+		d.MaxRange = max
+	}
+}
+
+// Sets whether runes and bytes can be written as characters.
+func WithChars(mode CharMode) DecoderOption {
+	return func(d *Decoder) { d.Chars = mode }
+}
+
+// Returns the multi-valued settings of the decoder.
+func (d *Decoder) multis() []*Multi {
+	return []*Multi{&d.Slice, &d.Array, &d.Map, &d.Struct}
+}
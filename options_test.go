@@ -0,0 +1,99 @@
+package refstr
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestDecoderOptions(t *testing.T) {
+	type version struct{ Major, Minor int }
+
+	parent := NewDecoder(
+		WithTrues("on"),
+		WithFalses("off"),
+		WithValueSeparator(regexp.MustCompile(`\s*;\s*`), ";"),
+		WithStrict(true),
+		WithInt(func(s string, bits int) (int64, error) { return strconv.ParseInt(s, 16, bits) }),
+		WithParser(TypeOf[version](), func(s string) (any, error) { return version{Major: 1}, nil }),
+	)
+
+	if val, err := parent.DecodeType(TypeOf[[]bool](), "[on;off]"); err != nil || !reflect.DeepEqual(val, []bool{true, false}) {
+		t.Errorf("unexpected bools %v %v", val, err)
+	}
+	if _, err := parent.DecodeType(TypeOf[[]bool](), "on;off"); err == nil {
+		t.Errorf("expected strict decoder to require delimiters")
+	}
+	if val, err := parent.DecodeType(TypeOf[int](), "ff"); err != nil || val != 255 {
+		t.Errorf("unexpected int %v %v", val, err)
+	}
+	if val, err := parent.DecodeType(TypeOf[version](), "x"); err != nil || val != (version{Major: 1}) {
+		t.Errorf("unexpected version %v %v", val, err)
+	}
+	if encoded, err := parent.Encode([]int{1, 2}); err != nil || encoded != "[1;2]" {
+		t.Errorf("unexpected encoding %v %v", encoded, err)
+	}
+
+	child := parent.With(
+		WithTrues("yes"),
+		WithStrict(false),
+		WithParser(TypeOf[version](), func(s string) (any, error) { return version{Major: 2}, nil }),
+	)
+
+	if val, err := child.DecodeType(TypeOf[bool](), "yes"); err != nil || val != true {
+		t.Errorf("unexpected child bool %v %v", val, err)
+	}
+	if val, err := child.DecodeType(TypeOf[version](), "x"); err != nil || val != (version{Major: 2}) {
+		t.Errorf("unexpected child version %v %v", val, err)
+	}
+	if _, err := parent.DecodeType(TypeOf[bool](), "yes"); err == nil {
+		t.Errorf("expected parent to be unaffected by child")
+	}
+	if val, err := parent.DecodeType(TypeOf[version](), "x"); err != nil || val != (version{Major: 1}) {
+		t.Errorf("expected parent parser to be unaffected by child, got %v %v", val, err)
+	}
+	if !parent.Slice.Strict {
+		t.Errorf("expected parent to remain strict")
+	}
+}
+
+func TestSetDefaultDecoder(t *testing.T) {
+	previous := DefaultDecoder()
+	defer SetDefaultDecoder(previous)
+
+	SetDefaultDecoder(NewDecoder(WithTrues("sure")))
+
+	var b bool
+	if err := Decode(&b, "sure"); err != nil || !b {
+		t.Errorf("expected new default decoder to be used, got %v %v", b, err)
+	}
+
+	set := NewDecoder()
+	SetDefaultDecoder(set)
+	set.Trues["zzz"] = struct{}{}
+	DefaultDecoder().Trues["zzz"] = struct{}{}
+	if err := Decode(&b, "zzz"); err == nil {
+		t.Errorf("expected the default decoder to be unaffected by changes to copies, got %v", b)
+	}
+
+	GetDefaultDecoder().Parsers[TypeOf[bool]()] = func(s string) (any, error) { return true, nil }
+	if err := Decode(&b, "zzz"); err != nil || !b {
+		t.Errorf("expected changes through GetDefaultDecoder to apply, got %v %v", b, err)
+	}
+}
+
+func TestSetParserCopies(t *testing.T) {
+	parent := NewDecoder()
+	child := parent
+	child.SetParser(TypeOf[bool](), func(s string) (any, error) { return true, nil })
+	child.SetHint(TypeOf[bool](), "anything")
+	child.SetEnum(TypeOf[int](), EnumValue{Name: "one", Value: 1})
+
+	if len(parent.Parsers) != 0 || len(parent.Hints) != 0 || len(parent.Enums) != 0 {
+		t.Errorf("expected the decoder copied from to be unaffected")
+	}
+	if val, err := child.DecodeType(TypeOf[bool](), "nope"); err != nil || val != true {
+		t.Errorf("expected the parser to be used, got %v %v", val, err)
+	}
+}
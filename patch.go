@@ -0,0 +1,192 @@
+package refstr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// The operation of a patch.
+type PatchOp string
+
+const (
+	// Inserts the value at a slice index, or sets it anywhere else.
+	PatchAdd PatchOp = "add"
+	// Removes the existing value.
+	PatchRemove PatchOp = "remove"
+	// Replaces the existing value.
+	PatchReplace PatchOp = "replace"
+	// Removes the existing value at from and adds it at the path.
+	PatchMove PatchOp = "move"
+	// Adds a copy of the existing value at from at the path.
+	PatchCopy PatchOp = "copy"
+	// Fails the patch if the existing value is not the value.
+	PatchTest PatchOp = "test"
+)
+
+// An operation of a patch on a path like ByName[John].Name.First. A value
+// which is a string is decoded into the type of the path, JSON numbers,
+// objects and arrays are unmarshaled into it and other values are set as they
+// are or converted if they can't be.
+type PatchOperation struct {
+	Op    PatchOp `json:"op"`
+	Path  string  `json:"path"`
+	From  string  `json:"from,omitempty"`
+	Value any     `json:"value,omitempty"`
+}
+
+// A list of operations which are applied together or not at all.
+type Patch []PatchOperation
+
+// Parses a patch from a JSON list of operations like
+// [{"op": "replace", "path": "Name", "value": "John"}]. Numbers in values are
+// kept as json.Number so large integers don't lose precision.
+func ParsePatch(data []byte) (Patch, error) {
+	patch := Patch{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the patch")
+	}
+	for i, op := range patch {
+		switch op.Op {
+		case PatchAdd, PatchRemove, PatchReplace, PatchMove, PatchCopy, PatchTest:
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op '%s'", i, op.Op)
+		}
+	}
+	return patch, nil
+}
+
+// Returns a patch which turns a into b when applied to a.
+func NewPatch(a, b any) Patch {
+	patch := make(Patch, 0)
+	removes := make(Patch, 0)
+	for _, change := range Diff(a, b) {
+		switch change.Kind {
+		case ChangeAdded:
+			patch = append(patch, PatchOperation{Op: PatchAdd, Path: change.Path.String(), Value: patchValue(change.New)})
+		case ChangeModified:
+			patch = append(patch, PatchOperation{Op: PatchReplace, Path: change.Path.String(), Value: patchValue(change.New)})
+		case ChangeRemoved:
+			removes = append(removes, PatchOperation{Op: PatchRemove, Path: change.Path.String()})
+		}
+	}
+	// Trailing slice elements are removed last to first so indices don't shift.
+	for i := len(removes) - 1; i >= 0; i-- {
+		patch = append(patch, removes[i])
+	}
+	return patch
+}
+
+// Returns the value of a change for a patch.
+func patchValue(rv reflect.Value) any {
+	if !rv.IsValid() || !rv.CanInterface() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// Applies the patch to the value, which needs to be a pointer, using the
+// default decoder for paths and string values. If an operation fails the
+// value is restored and a TxError is returned.
+func (p Patch) Apply(root any) error {
+	return BeginTx(root).Patch(p).Commit()
+}
+
+// Applies the patch to the value, which needs to be a pointer, using this
+// decoder for paths and string values.
+func (d Decoder) ApplyPatch(root any, p Patch) error {
+	return BeginTx(root).patch(&d, p).Commit()
+}
+
+// Adds the operations of the patch to the transaction.
+func (tx *Tx) Patch(p Patch) *Tx {
+	return tx.patch(defaultDecoder.Load(), p)
+}
+
+// Adds the operations of the patch with paths and values decoded by d.
+func (tx *Tx) patch(d *Decoder, p Patch) *Tx {
+	for _, op := range p {
+		added := txOp{fromText: op.From}
+		switch op.Op {
+		case PatchAdd:
+			added.kind = txAdd
+		case PatchRemove:
+			added.kind = txRemove
+		case PatchReplace:
+			added.kind = txReplace
+		case PatchMove:
+			added.kind = txMove
+		case PatchCopy:
+			added.kind = txCopy
+		case PatchTest:
+			added.kind = txTest
+		default:
+			added.err = fmt.Errorf("unknown op '%s'", op.Op)
+		}
+		tx.addWith(d, op.Path, added)
+
+		last := &tx.ops[len(tx.ops)-1]
+		if last.err == nil && (last.kind == txAdd || last.kind == txReplace || last.kind == txTest) {
+			last.value, last.err = d.patchValue(last.path.Type(), op.Value)
+		}
+	}
+	return tx
+}
+
+// Returns the value of an operation for the type of its path. JSON numbers,
+// objects and arrays are unmarshaled into it, numbers which can't be are
+// decoded like strings are, and other values which can't be assigned are
+// converted.
+func (d *Decoder) patchValue(rt reflect.Type, value any) (reflect.Value, error) {
+	rv := Reflect(value)
+	if rt == nil || !rv.IsValid() {
+		return rv, nil
+	}
+	var converted any
+	var err error
+	switch v := value.(type) {
+	case json.Number, json.RawMessage, map[string]any, []any:
+		converted, err = unmarshalJSON(rt, v)
+		if n, ok := v.(json.Number); ok && err != nil {
+			converted, err = d.DecodeType(rt, n.String())
+		}
+	case string:
+		if rv.Type().AssignableTo(rt) {
+			return rv, nil
+		}
+		converted, err = d.DecodeType(rt, v)
+	default:
+		if rv.Type().AssignableTo(rt) {
+			return rv, nil
+		}
+		converted, err = d.Convert(value, rt)
+	}
+	if err != nil {
+		return rv, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	return Reflect(converted), nil
+}
+
+// Unmarshals raw JSON, or a JSON object or array parsed into maps and slices,
+// into a new value of the type.
+func unmarshalJSON(rt reflect.Type, value any) (any, error) {
+	raw, ok := value.(json.RawMessage)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+	target := reflect.New(rt)
+	if err := json.Unmarshal(raw, target.Interface()); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
@@ -0,0 +1,175 @@
+package refstr
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    string
+		expected testConfig
+		err      error
+	}{{
+		name:  "replace decoded",
+		patch: `[{"op": "replace", "path": "Servers[0].Port", "value": "8080"}]`,
+		expected: testConfig{
+			Name: "a", Servers: []testServer{{Host: "x", Port: 8080}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t1", "t2"},
+		},
+	}, {
+		name:  "native values",
+		patch: `[{"op": "add", "path": "Limits[memory]", "value": 512}, {"op": "replace", "path": "Name", "value": "b"}]`,
+		expected: testConfig{
+			Name: "b", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1, "memory": 512}, Tags: []string{"t1", "t2"},
+		},
+	}, {
+		name:  "objects and arrays",
+		patch: `[{"op": "add", "path": "Servers[0]", "value": {"Host": "z", "Port": 3}}, {"op": "replace", "path": "Tags", "value": ["t3"]}]`,
+		expected: testConfig{
+			Name: "a", Servers: []testServer{{Host: "z", Port: 3}, {Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t3"},
+		},
+	}, {
+		name:  "invalid object",
+		patch: `[{"op": "replace", "path": "Servers[0]", "value": {"Port": "http"}}]`,
+		err:   ErrInvalidValue,
+	}, {
+		name:  "add inserts",
+		patch: `[{"op": "add", "path": "Tags[0]", "value": "t0"}, {"op": "add", "path": "Tags[+]", "value": "t3"}]`,
+		expected: testConfig{
+			Name: "a", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t0", "t1", "t2", "t3"},
+		},
+	}, {
+		name:  "remove",
+		patch: `[{"op": "remove", "path": "Servers[0]"}, {"op": "remove", "path": "Limits[cpu]"}]`,
+		expected: testConfig{
+			Name: "a", Servers: []testServer{{Host: "y", Port: 2}},
+			Limits: map[string]int{}, Tags: []string{"t1", "t2"},
+		},
+	}, {
+		name:  "move and copy",
+		patch: `[{"op": "move", "from": "Tags[0]", "path": "Tags[1]"}, {"op": "copy", "from": "Servers[1].Host", "path": "Name"}]`,
+		expected: testConfig{
+			Name: "y", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t2", "t1"},
+		},
+	}, {
+		name:  "test passes",
+		patch: `[{"op": "test", "path": "Servers[1].Port", "value": 2}, {"op": "replace", "path": "Name", "value": "b"}]`,
+		expected: testConfig{
+			Name: "b", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t1", "t2"},
+		},
+	}, {
+		name:  "test fails",
+		patch: `[{"op": "replace", "path": "Name", "value": "b"}, {"op": "test", "path": "Servers[1].Port", "value": 3}]`,
+		err:   ErrTestFailed,
+	}, {
+		name:  "remove missing",
+		patch: `[{"op": "add", "path": "Tags[0]", "value": "t0"}, {"op": "remove", "path": "Limits[disk]"}]`,
+		err:   ErrValueMissing,
+	}, {
+		name:  "invalid value",
+		patch: `[{"op": "replace", "path": "Servers[0].Port", "value": "http"}]`,
+		err:   ErrInvalidValue,
+	}, {
+		name:  "invalid path",
+		patch: `[{"op": "replace", "path": "Servers[0].Missing", "value": 1}]`,
+		err:   ErrInvalidPath,
+	}}
+
+	for _, test := range tests {
+		config := testConfig{
+			Name: "a", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Limits: map[string]int{"cpu": 1}, Tags: []string{"t1", "t2"},
+		}
+		original := config
+		original.Servers = append([]testServer(nil), config.Servers...)
+		original.Tags = append([]string(nil), config.Tags...)
+		original.Limits = map[string]int{"cpu": 1}
+
+		patch, err := ParsePatch([]byte(test.patch))
+		if err != nil {
+			t.Errorf("[%s] unexpected parse error %v", test.name, err)
+			continue
+		}
+		err = patch.Apply(&config)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+			}
+			if !reflect.DeepEqual(config, original) {
+				t.Errorf("[%s] expected %+v to be restored but got %+v", test.name, original, config)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, test.expected) {
+			t.Errorf("[%s] expected %+v but got %+v", test.name, test.expected, config)
+		}
+	}
+}
+
+func TestParsePatchInvalid(t *testing.T) {
+	if _, err := ParsePatch([]byte(`[{"op": "rename", "path": "Name"}]`)); err == nil {
+		t.Errorf("expected an unknown op to fail")
+	}
+}
+
+func TestNewPatch(t *testing.T) {
+	a := testConfig{
+		Name: "a", Servers: []testServer{{Host: "x", Port: 1}, {Host: "y", Port: 2}, {Host: "z"}},
+		Limits: map[string]int{"cpu": 1, "disk": 3}, Tags: []string{"t1"},
+	}
+	b := testConfig{
+		Name: "b", Servers: []testServer{{Host: "x", Port: 5}},
+		Limits: map[string]int{"cpu": 2, "memory": 4}, Tags: []string{"t1", "t2", "t3"},
+	}
+
+	patch := NewPatch(a, b)
+	if err := patch.Apply(&a); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("expected %+v but got %+v from %+v", b, a, patch)
+	}
+}
+
+func TestPatchJSONRoundTrip(t *testing.T) {
+	type sizes struct {
+		Server testServer
+		Limits map[string]int64
+		Counts []uint64
+	}
+	a := sizes{Limits: map[string]int64{}}
+	b := sizes{
+		Server: testServer{Host: "x", Port: 1000000},
+		Limits: map[string]int64{"max": math.MaxInt64, "min": math.MinInt64},
+		Counts: []uint64{math.MaxUint64, 1 << 60},
+	}
+
+	data, err := json.Marshal(NewPatch(a, b))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	patch, err := ParsePatch(data)
+	if err != nil {
+		t.Fatalf("unexpected parse error %v", err)
+	}
+	if err := patch.Apply(&a); err != nil {
+		t.Fatalf("unexpected error %v applying %s", err, data)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("expected %+v but got %+v from %s", b, a, data)
+	}
+}
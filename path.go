@@ -3,6 +3,7 @@ package refstr
 import (
 	"errors"
 	"reflect"
+	"sync"
 )
 
 // Set might not be supported because a pointer was not provided or a node
@@ -16,6 +17,22 @@ var ErrGetNotSupported = errors.New("get not available on this value")
 // The value returned by get is not a valid value.
 var ErrGetInvalid = errors.New("get has invalid result")
 
+// Delete might not be supported because the node is read-only, is an array
+// element, or is a method.
+var ErrDeleteNotSupported = errors.New("delete not available on this value")
+
+// Insert is only supported on slice indices.
+var ErrInsertNotSupported = errors.New("insert not available on this value")
+
+// Move is only supported on slice and array indices.
+var ErrMoveNotSupported = errors.New("move not available on this value")
+
+// A value along the path doesn't exist.
+var errPathMissing = errors.New("path does not exist")
+
+// The index or range of a slice or array is outside of its bounds.
+var ErrIndexOutOfRange = errors.New("index out of range")
+
 // A path of keys/fields/indices to a value that can be gotten or set.
 type Path struct {
 	root  reflect.Type
@@ -53,7 +70,8 @@ func (p Path) Keys() []any {
 	return keys
 }
 
-// Returns the expected return type of this path.
+// Returns the expected return type of this path, or nil if it's only known
+// once the path is expanded against a value.
 func (p Path) Type() reflect.Type {
 	if p.IsEmpty() {
 		return p.root
@@ -64,6 +82,9 @@ func (p Path) Type() reflect.Type {
 
 // Returns the next available nodes at the end of this path.
 func (p Path) NextNodes() *Nodes {
+	if p.Type() == nil {
+		return NewNodes(nil)
+	}
 	return GetTypeNodes(p.Type())
 }
 
@@ -77,10 +98,16 @@ func (p Path) Next(key any) *Path {
 		return nil
 	}
 
+	next := p.append(*nextNode)
+	return &next
+}
+
+// Returns a new path with the node added to the end.
+func (p Path) append(node Node) Path {
 	next := NewPath(p.root)
 	next.nodes = append(next.nodes, p.nodes...)
-	next.nodes = append(next.nodes, *nextNode)
-	return &next
+	next.nodes = append(next.nodes, node)
+	return next
 }
 
 // Returns the root type for this path.
@@ -110,13 +137,26 @@ func (p Path) Get(root any) (reflect.Value, error) {
 	if p.IsEmpty() {
 		return rv, nil
 	}
+	if p.IsPattern() {
+		resolved, err := p.resolveFilters(rv)
+		if err != nil {
+			return invalidValue, err
+		}
+		p = resolved
+	}
 
 	for _, node := range p.nodes {
 		if node.Get == nil {
 			return invalidValue, ErrGetNotSupported
 		}
+		if !Concrete(rv).IsValid() {
+			return invalidValue, ErrGetInvalid
+		}
 		rv = node.Get(node, rv)
 		if !rv.IsValid() {
+			if node.Kind == NodeIndex || node.Kind == NodeRange || node.Kind == NodeAppend {
+				return rv, ErrIndexOutOfRange
+			}
 			return rv, ErrGetInvalid
 		}
 	}
@@ -128,6 +168,14 @@ func (p Path) Get(root any) (reflect.Value, error) {
 func (p Path) Set(root any, val any) error {
 	rv := Reflect(root)
 
+	if p.IsPattern() {
+		resolved, err := p.resolveFilters(rv)
+		if err != nil {
+			return err
+		}
+		p = resolved
+	}
+
 	last := len(p.nodes) - 1
 
 	if last == -1 {
@@ -139,9 +187,109 @@ func (p Path) Set(root any, val any) error {
 		return ErrSetNotSupported
 	}
 
+	return p.apply(rv, true, func(node Node, parent reflect.Value) error {
+		if node.Set == nil {
+			return ErrSetNotSupported
+		}
+		return node.Set(node, parent, Reflect(val))
+	})
+}
+
+// Deletes the value at this path for the given v. Map keys and slice elements
+// are removed, and fields are set to their zero value. Deleting a value which
+// doesn't exist, including an index beyond the length of a slice, does nothing.
+func (p Path) Delete(root any) error {
+	rv := Reflect(root)
+
+	if p.IsPattern() {
+		return ErrPathPattern
+	}
+
+	if p.IsEmpty() {
+		if rv.CanSet() {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		return ErrDeleteNotSupported
+	}
+
+	err := p.apply(rv, false, func(node Node, parent reflect.Value) error {
+		if node.Delete == nil {
+			return ErrDeleteNotSupported
+		}
+		return node.Delete(node, parent)
+	})
+	if err == errPathMissing {
+		return nil
+	}
+	return err
+}
+
+// Inserts the value into the slice at the index of this path for the given v,
+// shifting the elements at and after the index up. Inserting at the length of
+// the slice appends to it.
+func (p Path) Insert(root any, val any) error {
+	if p.IsPattern() {
+		return ErrPathPattern
+	}
+	if p.IsEmpty() {
+		return ErrInsertNotSupported
+	}
+
+	return p.apply(Reflect(root), true, func(node Node, parent reflect.Value) error {
+		if node.Insert == nil {
+			return ErrInsertNotSupported
+		}
+		return node.Insert(node, parent, Reflect(val))
+	})
+}
+
+// Moves the slice or array element at this path for the given v to another
+// index, shifting the elements between them.
+func (p Path) Move(root any, to int) error {
+	if p.IsPattern() {
+		return ErrPathPattern
+	}
+	if p.IsEmpty() || p.End().Kind != NodeIndex {
+		return ErrMoveNotSupported
+	}
+
+	err := p.apply(Reflect(root), false, func(node Node, parent reflect.Value) error {
+		c := Concrete(parent)
+		from, fromOk := resolveIndex(node.Key.(int), c.Len())
+		to, toOk := resolveIndex(to, c.Len())
+		if !fromOk || !toOk {
+			return ErrIndexOutOfRange
+		}
+		if !c.Index(from).CanSet() {
+			return ErrSetNotSupported
+		}
+		moving := reflect.New(c.Type().Elem()).Elem()
+		moving.Set(c.Index(from))
+		if from < to {
+			reflect.Copy(c.Slice(from, to), c.Slice(from+1, to+1))
+		} else {
+			reflect.Copy(c.Slice(to+1, from+1), c.Slice(to, from))
+		}
+		c.Index(to).Set(moving)
+		return nil
+	})
+	if err == errPathMissing {
+		return ErrIndexOutOfRange
+	}
+	return err
+}
+
+// Applies the action to the last node of the path and the value it's on.
+// The values along the path are created if they don't exist and create is
+// true, otherwise errPathMissing is returned. Values which are copies, like map values,
+// are set back into the values they came from.
+func (p Path) apply(rv reflect.Value, create bool, action func(node Node, parent reflect.Value) error) error {
+	last := len(p.nodes) - 1
+
 	for i := 0; i <= last; i++ {
 		node := p.nodes[i]
-		if node.Set == nil {
+		if node.Set == nil && (i < last || create) {
 			return ErrSetNotSupported
 		}
 		if node.Get == nil && i < last {
@@ -160,8 +308,11 @@ func (p Path) Set(root any, val any) error {
 			setBackTo = i
 		}
 
-		next := node.Get(node, values[i])
+		next := getNode(node, values[i])
 		if !next.IsValid() {
+			if !create {
+				return errPathMissing
+			}
 			next = InitType(node.Type)
 			if !next.IsValid() {
 				return ErrGetInvalid
@@ -175,13 +326,16 @@ func (p Path) Set(root any, val any) error {
 			ptr.Elem().Set(next)
 			next = ptr.Elem()
 		}
-		if !InitValue(next, node.Type) {
+		if !create && !Concrete(next).IsValid() {
+			return errPathMissing
+		}
+		if create && !InitValue(next, node.Type) {
 			return ErrGetInvalid
 		}
 		values[i+1] = next
 	}
 
-	err := p.nodes[last].Set(p.nodes[last], values[last], Reflect(val))
+	err := action(p.nodes[last], values[last])
 	if err != nil {
 		return err
 	}
@@ -210,8 +364,9 @@ func (p Path) SetString(root any, s string) error {
 
 // A reference to a value in a path
 type Ref struct {
-	root reflect.Value
-	path Path
+	root  reflect.Value
+	path  Path
+	store *Store
 }
 
 // A new reference given a value. If the set methods will be called
@@ -229,11 +384,12 @@ func (r Ref) Path() Path {
 	return r.path
 }
 
-// Returns the available nodes based on the value in this reference.
+// Returns the available nodes based on the value in this reference, or on
+// its type if the value doesn't exist.
 func (r Ref) NextNodes() *Nodes {
 	rv, err := r.Get()
-	if err == nil {
-		return nil
+	if err != nil {
+		return r.path.NextNodes()
 	}
 	return GetValueNodes(rv)
 }
@@ -245,9 +401,15 @@ func (r Ref) Next(key any) *Ref {
 		return nil
 	}
 
+	return r.at(*nextPath)
+}
+
+// Returns a reference to the given path on the same root.
+func (r Ref) at(path Path) *Ref {
 	return &Ref{
-		root: r.root,
-		path: *nextPath,
+		root:  r.root,
+		path:  path,
+		store: r.store,
 	}
 }
 
@@ -258,22 +420,73 @@ func (r Ref) Nexts(keys []any) *Ref {
 	} else if len(keys) == 1 {
 		return r.Next(keys[0])
 	} else {
-		return r.Next(keys[0]).Nexts(keys[1:])
+		next := r.Next(keys[0])
+		if next == nil {
+			return nil
+		}
+		return next.Nexts(keys[1:])
 	}
 }
 
 // Gets the referenced value.
 func (r Ref) Get() (reflect.Value, error) {
+	if r.store != nil {
+		return r.store.value(r.path)
+	}
 	return r.path.Get(r.root)
 }
 
 // Sets the referenced value.
 func (r Ref) Set(value any) error {
+	if r.store != nil {
+		return r.store.write(func() (*Change, error) {
+			return r.store.set(r.path, value, nil)
+		})
+	}
 	return r.path.Set(r.root, value)
 }
 
+// Deletes the referenced value.
+func (r Ref) Delete() error {
+	if r.store != nil {
+		return r.store.write(func() (*Change, error) {
+			return r.store.delete(r.path, nil)
+		})
+	}
+	return r.path.Delete(r.root)
+}
+
+// Inserts the value at the referenced slice index.
+func (r Ref) Insert(value any) error {
+	if r.store != nil {
+		return r.store.write(func() (*Change, error) {
+			return r.store.insert(r.path, value, nil)
+		})
+	}
+	return r.path.Insert(r.root, value)
+}
+
+// Moves the referenced slice or array element to another index.
+func (r Ref) Move(to int) error {
+	if r.store != nil {
+		return r.store.write(func() (*Change, error) {
+			return r.store.move(r.path, to, nil)
+		})
+	}
+	return r.path.Move(r.root, to)
+}
+
 // Sets the referenced value from a string.
 func (r Ref) SetString(value string) error {
+	if r.store != nil {
+		parsed, err := DecodeType(r.path.Type(), value)
+		if err != nil {
+			return err
+		}
+		return r.store.write(func() (*Change, error) {
+			return r.store.set(r.path, parsed, nil)
+		})
+	}
 	return r.path.SetString(r.root, value)
 }
 
@@ -282,18 +495,29 @@ var indexType = TypeOf[int]()
 var errorType = TypeOf[error]()
 
 var indexGet NodeGet = func(n Node, rv reflect.Value) reflect.Value {
-	return Concrete(rv).Index(n.Key.(int))
+	c := Concrete(rv)
+	index, ok := resolveIndex(n.Key.(int), c.Len())
+	if !ok {
+		return invalidValue
+	}
+	return c.Index(index)
 }
 
 var indexSet NodeSet = func(n Node, rv, val reflect.Value) error {
 	index := n.Key.(int)
 	c := Concrete(rv)
 	if index < 0 {
-		return ErrSetNotSupported
+		index += c.Len()
+		if index < 0 {
+			return ErrIndexOutOfRange
+		}
 	}
-	for index >= c.Len() && c.CanSet() {
+	for index >= c.Len() && c.Kind() == reflect.Slice && c.CanSet() {
 		c.Set(reflect.Append(c, reflect.New(c.Type().Elem()).Elem()))
 	}
+	if index >= c.Len() {
+		return ErrIndexOutOfRange
+	}
 	el := c.Index(index)
 	if !el.CanSet() {
 		return ErrSetNotSupported
@@ -302,6 +526,48 @@ var indexSet NodeSet = func(n Node, rv, val reflect.Value) error {
 	return nil
 }
 
+// Removes the element from a slice, array elements can't be removed.
+var indexDelete NodeDelete = func(n Node, rv reflect.Value) error {
+	c := Concrete(rv)
+	if c.Kind() != reflect.Slice || !c.CanSet() {
+		return ErrDeleteNotSupported
+	}
+	index, ok := resolveIndex(n.Key.(int), c.Len())
+	if !ok {
+		return errPathMissing
+	}
+	removeElements(c, index, index+1)
+	return nil
+}
+
+// Inserts the element into a slice, inserting at the length appends.
+var indexInsert NodeSet = func(n Node, rv, val reflect.Value) error {
+	c := Concrete(rv)
+	if c.Kind() != reflect.Slice || !c.CanSet() {
+		return ErrInsertNotSupported
+	}
+	index := n.Key.(int)
+	if index < 0 {
+		index += c.Len()
+	}
+	if index < 0 || index > c.Len() {
+		return ErrIndexOutOfRange
+	}
+	c.Set(reflect.Append(c, reflect.Zero(c.Type().Elem())))
+	reflect.Copy(c.Slice(index+1, c.Len()), c.Slice(index, c.Len()-1))
+	c.Index(index).Set(val)
+	return nil
+}
+
+// Returns the index for a length where negative indices are from the end and
+// whether it's in range.
+func resolveIndex(index int, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
 var mapGet NodeGet = func(n Node, rv reflect.Value) reflect.Value {
 	return Concrete(rv).MapIndex(Reflect(n.Key))
 }
@@ -311,65 +577,75 @@ var mapSet NodeSet = func(n Node, rv, val reflect.Value) error {
 	return nil
 }
 
-var fieldGetMap map[int]NodeGet = make(map[int]NodeGet)
+var mapDelete NodeDelete = func(n Node, rv reflect.Value) error {
+	Concrete(rv).SetMapIndex(Reflect(n.Key), reflect.Value{})
+	return nil
+}
+
+// Sets the value of the node to its zero value.
+var zeroDelete NodeDelete = func(n Node, rv reflect.Value) error {
+	return n.Set(n, rv, reflect.Zero(n.Type))
+}
+
+var fieldGetMap sync.Map
 
 func getFieldGet(i int) NodeGet {
-	fn := fieldGetMap[i]
-	if fn == nil {
-		fn = func(n Node, rv reflect.Value) reflect.Value {
-			return Concrete(rv).Field(i)
-		}
-		fieldGetMap[i] = fn
+	if fn, ok := fieldGetMap.Load(i); ok {
+		return fn.(NodeGet)
+	}
+	var fn NodeGet = func(n Node, rv reflect.Value) reflect.Value {
+		return Concrete(rv).Field(i)
 	}
-	return fn
+	actual, _ := fieldGetMap.LoadOrStore(i, fn)
+	return actual.(NodeGet)
 }
 
-var fieldSetMap map[int]NodeSet = make(map[int]NodeSet)
+var fieldSetMap sync.Map
 
 func getFieldSet(i int) NodeSet {
-	fn := fieldSetMap[i]
-	if fn == nil {
-		fn = func(n Node, rv, val reflect.Value) error {
-			f := Concrete(rv).Field(i)
-			if !f.CanSet() {
-				return ErrSetNotSupported
-			}
-			f.Set(val)
-			return nil
+	if fn, ok := fieldSetMap.Load(i); ok {
+		return fn.(NodeSet)
+	}
+	var fn NodeSet = func(n Node, rv, val reflect.Value) error {
+		f := Concrete(rv).Field(i)
+		if !f.CanSet() {
+			return ErrSetNotSupported
 		}
-		fieldSetMap[i] = fn
+		f.Set(val)
+		return nil
 	}
-	return fn
+	actual, _ := fieldSetMap.LoadOrStore(i, fn)
+	return actual.(NodeSet)
 }
 
-var methodGetMap map[int]NodeGet = make(map[int]NodeGet)
+var methodGetMap sync.Map
 
 func getMethodGet(i int) NodeGet {
-	fn := methodGetMap[i]
-	if fn == nil {
-		fn = func(n Node, rv reflect.Value) reflect.Value {
-			return rv.Method(i).Call([]reflect.Value{})[0]
-		}
-		methodGetMap[i] = fn
+	if fn, ok := methodGetMap.Load(i); ok {
+		return fn.(NodeGet)
 	}
-	return fn
+	var fn NodeGet = func(n Node, rv reflect.Value) reflect.Value {
+		return rv.Method(i).Call([]reflect.Value{})[0]
+	}
+	actual, _ := methodGetMap.LoadOrStore(i, fn)
+	return actual.(NodeGet)
 }
 
-var methodSetMap map[int]NodeSet = make(map[int]NodeSet)
+var methodSetMap sync.Map
 
 func getMethodSet(i int) NodeSet {
-	fn := methodSetMap[i]
-	if fn == nil {
-		fn = func(n Node, rv, val reflect.Value) error {
-			out := rv.Method(i).Call([]reflect.Value{val})
-			if len(out) == 1 && out[0].Type().Implements(errorType) {
-				return out[0].Interface().(error)
-			}
-			return nil
+	if fn, ok := methodSetMap.Load(i); ok {
+		return fn.(NodeSet)
+	}
+	var fn NodeSet = func(n Node, rv, val reflect.Value) error {
+		out := rv.Method(i).Call([]reflect.Value{val})
+		if len(out) == 1 && out[0].Type().Implements(errorType) && !out[0].IsNil() {
+			return out[0].Interface().(error)
 		}
-		methodSetMap[i] = fn
+		return nil
 	}
-	return fn
+	actual, _ := methodSetMap.LoadOrStore(i, fn)
+	return actual.(NodeSet)
 }
 
 var invalidValue = reflect.Value{}
@@ -1,6 +1,8 @@
 package refstr
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -256,3 +258,414 @@ func TestRefSet(t *testing.T) {
 		}
 	}
 }
+
+func TestSliceSteps(t *testing.T) {
+	type items struct {
+		Items  []int
+		Points []point
+		Pair   [2]int
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		set      any
+		expected any
+		err      error
+	}{{
+		name:     "last",
+		path:     "Items[-1]",
+		expected: 3,
+	}, {
+		name:     "set last",
+		path:     "Items[-1]",
+		set:      4,
+		expected: []int{1, 2, 4},
+	}, {
+		name: "get out of range",
+		path: "Items[3]",
+		err:  ErrIndexOutOfRange,
+	}, {
+		name: "get negative out of range",
+		path: "Items[-4]",
+		err:  ErrIndexOutOfRange,
+	}, {
+		name: "set negative out of range",
+		path: "Items[-4]",
+		set:  4,
+		err:  ErrIndexOutOfRange,
+	}, {
+		name:     "append",
+		path:     "Items[+]",
+		set:      4,
+		expected: []int{1, 2, 3, 4},
+	}, {
+		name: "get append",
+		path: "Items[+]",
+		err:  ErrIndexOutOfRange,
+	}, {
+		name:     "append struct",
+		path:     "Points[+].X",
+		set:      5,
+		expected: []point{{X: 5}},
+	}, {
+		name:     "range",
+		path:     "Items[1:3]",
+		expected: []int{2, 3},
+	}, {
+		name:     "range from end",
+		path:     "Items[-2:]",
+		expected: []int{2, 3},
+	}, {
+		name:     "range of array",
+		path:     "Pair[:1]",
+		expected: []int{7},
+	}, {
+		name: "range out of bounds",
+		path: "Items[2:5]",
+		err:  ErrIndexOutOfRange,
+	}, {
+		name:     "set range each",
+		path:     "Items[:2]",
+		set:      0,
+		expected: []int{0, 0, 3},
+	}, {
+		name:     "set range elements",
+		path:     "Items[1:]",
+		set:      []int{8, 9},
+		expected: []int{1, 8, 9},
+	}, {
+		name:     "set range shrinks",
+		path:     "Items[0:2]",
+		set:      []int{},
+		expected: []int{3},
+	}, {
+		name:     "set range grows",
+		path:     "Items[1:1]",
+		set:      [2]int{5, 6},
+		expected: []int{1, 5, 6, 2, 3},
+	}, {
+		name:     "set array range",
+		path:     "Pair[0:2]",
+		set:      []int{1, 2},
+		expected: [2]int{1, 2},
+	}, {
+		name: "set array range length",
+		path: "Pair[0:2]",
+		set:  []int{1},
+		err:  ErrSetNotSupported,
+	}}
+
+	for _, test := range tests {
+		value := &items{Items: []int{1, 2, 3}, Pair: [2]int{7, 8}}
+		ref, err := NewRef(value).NextPath(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+
+		var actual any
+		if test.set != nil {
+			err = ref.Set(test.set)
+			parent, _ := NewRef(value).NextPath(ref.Path().KeyStrings()[0])
+			if got, getErr := parent.Get(); getErr == nil {
+				actual = got.Interface()
+			}
+		} else {
+			var got reflect.Value
+			if got, err = ref.Get(); err == nil {
+				actual = got.Interface()
+			}
+		}
+
+		if !errors.Is(err, test.err) {
+			t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+			continue
+		}
+		if test.err == nil && !StringEqual(actual, test.expected) {
+			t.Errorf("[%s] expected %v but got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestRefDelete(t *testing.T) {
+	type owner struct {
+		Name  string
+		Point *point
+		Any   any
+		Tags  map[string][]int
+		Pair  [2]int
+		Items []string
+	}
+
+	tests := []struct {
+		name     string
+		value    any
+		path     string
+		getPath  string
+		expected any
+		err      error
+	}{{
+		name:     "map key",
+		value:    &map[string]int{"A": 1, "B": 2},
+		path:     "[A]",
+		expected: map[string]int{"B": 2},
+	}, {
+		name:     "missing map key",
+		value:    &map[string]int{"A": 1},
+		path:     "[B]",
+		expected: map[string]int{"A": 1},
+	}, {
+		name:     "map key in map value",
+		value:    &map[string]map[string]int{"A": {"B": 1, "C": 2}},
+		path:     "[A][B]",
+		expected: map[string]map[string]int{"A": {"C": 2}},
+	}, {
+		name:     "field in map value",
+		value:    &map[string]point{"A": {X: 1, Y: 2}},
+		path:     "[A].X",
+		expected: map[string]point{"A": {Y: 2}},
+	}, {
+		name:     "slice element in map value",
+		value:    &owner{Tags: map[string][]int{"A": {1, 2, 3}}},
+		path:     "Tags[A][1]",
+		getPath:  "Tags",
+		expected: map[string][]int{"A": {1, 3}},
+	}, {
+		name:     "slice element",
+		value:    &[]string{"a", "b", "c"},
+		path:     "[0]",
+		expected: []string{"b", "c"},
+	}, {
+		name:     "last slice element",
+		value:    &[]string{"a", "b", "c"},
+		path:     "[-1]",
+		expected: []string{"a", "b"},
+	}, {
+		name:     "slice range",
+		value:    &owner{Items: []string{"a", "b", "c", "d"}},
+		path:     "Items[1:3]",
+		getPath:  "Items",
+		expected: []string{"a", "d"},
+	}, {
+		name:     "slice out of range",
+		value:    &[]string{"a"},
+		path:     "[1]",
+		expected: []string{"a"},
+	}, {
+		name:     "slice range out of range",
+		value:    &[]string{"a"},
+		path:     "[2:4]",
+		expected: []string{"a"},
+	}, {
+		name:     "field",
+		value:    &owner{Name: "x"},
+		path:     "Name",
+		getPath:  "Name",
+		expected: "",
+	}, {
+		name:     "pointer",
+		value:    &owner{Point: &point{X: 1}},
+		path:     "Point",
+		getPath:  "Point",
+		expected: (*point)(nil),
+	}, {
+		name:     "interface",
+		value:    &owner{Any: 5},
+		path:     "Any",
+		getPath:  "Any",
+		expected: nil,
+	}, {
+		name:     "through nil pointer",
+		value:    &owner{},
+		path:     "Point.X",
+		getPath:  "Point",
+		expected: (*point)(nil),
+	}, {
+		name:  "array element",
+		value: &owner{},
+		path:  "Pair[0]",
+		err:   ErrDeleteNotSupported,
+	}, {
+		name:  "getter",
+		value: &point{},
+		path:  "Sum",
+		err:   ErrDeleteNotSupported,
+	}, {
+		name:  "setter",
+		value: &point{},
+		path:  "Set",
+		err:   ErrDeleteNotSupported,
+	}}
+
+	for _, test := range tests {
+		ref, err := NewRef(test.value).NextPath(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		err = ref.Delete()
+		if !errors.Is(err, test.err) {
+			t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		actual := reflect.ValueOf(test.value).Elem()
+		if test.getPath != "" {
+			getRef, _ := NewRef(test.value).NextPath(test.getPath)
+			if actual, err = getRef.Get(); err != nil {
+				t.Errorf("[%s] unexpected error %v", test.name, err)
+				continue
+			}
+		}
+		if !StringEqual(actual.Interface(), test.expected) {
+			t.Errorf("[%s] expected %v but got %v", test.name, test.expected, actual.Interface())
+		}
+	}
+}
+
+func TestRefInsertMove(t *testing.T) {
+	type list struct {
+		Items  []string
+		Pair   [3]int
+		Groups map[string][]string
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		insert   any
+		move     int
+		getPath  string
+		expected any
+		err      error
+	}{{
+		name:     "insert",
+		path:     "Items[1]",
+		insert:   "x",
+		getPath:  "Items",
+		expected: []string{"a", "x", "b", "c"},
+	}, {
+		name:     "insert first",
+		path:     "Items[0]",
+		insert:   "x",
+		getPath:  "Items",
+		expected: []string{"x", "a", "b", "c"},
+	}, {
+		name:     "insert at length",
+		path:     "Items[3]",
+		insert:   "x",
+		getPath:  "Items",
+		expected: []string{"a", "b", "c", "x"},
+	}, {
+		name:     "insert before last",
+		path:     "Items[-1]",
+		insert:   "x",
+		getPath:  "Items",
+		expected: []string{"a", "b", "x", "c"},
+	}, {
+		name:     "insert append",
+		path:     "Items[+]",
+		insert:   "x",
+		getPath:  "Items",
+		expected: []string{"a", "b", "c", "x"},
+	}, {
+		name:   "insert out of range",
+		path:   "Items[5]",
+		insert: "x",
+		err:    ErrIndexOutOfRange,
+	}, {
+		name:     "insert in map value",
+		path:     "Groups[g][0]",
+		insert:   "x",
+		getPath:  "Groups",
+		expected: map[string][]string{"g": {"x", "y"}},
+	}, {
+		name:     "insert in new map value",
+		path:     "Groups[h][0]",
+		insert:   "x",
+		getPath:  "Groups",
+		expected: map[string][]string{"g": {"y"}, "h": {"x"}},
+	}, {
+		name:   "insert array",
+		path:   "Pair[0]",
+		insert: 1,
+		err:    ErrInsertNotSupported,
+	}, {
+		name:   "insert field",
+		path:   "Items",
+		insert: []string{},
+		err:    ErrInsertNotSupported,
+	}, {
+		name:     "move down",
+		path:     "Items[0]",
+		move:     2,
+		getPath:  "Items",
+		expected: []string{"b", "c", "a"},
+	}, {
+		name:     "move up",
+		path:     "Items[-1]",
+		move:     0,
+		getPath:  "Items",
+		expected: []string{"c", "a", "b"},
+	}, {
+		name:     "move array",
+		path:     "Pair[2]",
+		move:     1,
+		getPath:  "Pair",
+		expected: [3]int{1, 3, 2},
+	}, {
+		name:     "move in map value",
+		path:     "Groups[g][0]",
+		move:     0,
+		getPath:  "Groups",
+		expected: map[string][]string{"g": {"y"}},
+	}, {
+		name: "move out of range",
+		path: "Items[0]",
+		move: 3,
+		err:  ErrIndexOutOfRange,
+	}, {
+		name: "move missing",
+		path: "Groups[h][0]",
+		move: 0,
+		err:  ErrIndexOutOfRange,
+	}, {
+		name: "move field",
+		path: "Items",
+		move: 0,
+		err:  ErrMoveNotSupported,
+	}}
+
+	for _, test := range tests {
+		value := &list{Items: []string{"a", "b", "c"}, Pair: [3]int{1, 2, 3}, Groups: map[string][]string{"g": {"y"}}}
+		ref, err := NewRef(value).NextPath(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if test.insert != nil {
+			err = ref.Insert(test.insert)
+		} else {
+			err = ref.Move(test.move)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		getRef, _ := NewRef(value).NextPath(test.getPath)
+		actual, err := getRef.Get()
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if !StringEqual(actual.Interface(), test.expected) {
+			t.Errorf("[%s] expected %v but got %v", test.name, test.expected, actual.Interface())
+		}
+	}
+}
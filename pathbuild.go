@@ -0,0 +1,200 @@
+package refstr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The key of a path step is not a field, method or key of the type.
+var ErrUnknownKey = errors.New("unknown key")
+
+// The key of a path step can't be converted to the key type of the map,
+// slice or array.
+var ErrKeyType = errors.New("wrong key type")
+
+// A step of a path which couldn't be built or can't be set.
+type PathError struct {
+	// The path up to the failed step.
+	Path Path
+	// The index of the failed step in the path.
+	Step int
+	Key  any
+	Err  error
+	// The keys which are valid at the failed step. Maps, slices and arrays
+	// accept any key of their key type instead.
+	Keys []string
+}
+
+// Returns the failed step, why and the valid keys.
+func (e *PathError) Error() string {
+	at := fmt.Sprintf("step %d '%v'", e.Step, e.Key)
+	if !e.Path.IsEmpty() {
+		at += fmt.Sprintf(" after '%s'", e.Path.String())
+	}
+	if len(e.Keys) > 0 {
+		return fmt.Sprintf("%s: %v, valid keys are %s", at, e.Err, strings.Join(e.Keys, ", "))
+	}
+	return fmt.Sprintf("%s: %v", at, e.Err)
+}
+
+// Returns why the step failed.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Builds a path from the root type following the keys, returning a
+// *PathError for the first key which isn't valid.
+func BuildPath(root reflect.Type, keys ...any) (Path, error) {
+	return defaultDecoder.Load().BuildPath(root, keys...)
+}
+
+// Builds a path like BuildPath and checks that it can be set, returning a
+// *PathError for the first step which is read-only.
+func BuildSetPath(root reflect.Type, keys ...any) (Path, error) {
+	return defaultDecoder.Load().BuildSetPath(root, keys...)
+}
+
+// Builds a path from the root type following the keys, decoding string keys
+// with this decoder. See BuildPath.
+func (d Decoder) BuildPath(root reflect.Type, keys ...any) (Path, error) {
+	return d.tryNexts(NewPath(root), keys)
+}
+
+// Builds a path like BuildPath and checks that it can be set. See BuildSetPath.
+func (d Decoder) BuildSetPath(root reflect.Type, keys ...any) (Path, error) {
+	path, err := d.BuildPath(root, keys...)
+	if err != nil {
+		return path, err
+	}
+	return path, path.CheckSet()
+}
+
+// Returns the path following the key or a *PathError explaining why the key
+// isn't valid: it's not a field, method or key of the type, it can't be
+// converted to the key type of a map, slice or array, or it's beyond the
+// length of an array. Strings are decoded into other key types.
+func (p Path) TryNext(key any) (Path, error) {
+	return defaultDecoder.Load().tryNext(p, key)
+}
+
+// Returns the path following the key, decoding string keys with this decoder.
+func (d *Decoder) tryNext(p Path, key any) (Path, error) {
+	nodes := p.NextNodes()
+	fail := func(err error) (Path, error) {
+		return p, &PathError{Path: p, Step: len(p.nodes), Key: key, Err: err, Keys: nodes.KeyStrings()}
+	}
+
+	rt := p.Type()
+	if rt == nil {
+		return fail(fmt.Errorf("%w: the type is not known", ErrUnknownKey))
+	}
+	if node, exists := nodes.ByKey[ToString(key)]; exists && !node.IsDynamic() {
+		return p.append(node), nil
+	}
+	if c := ConcreteType(rt); c.Kind() == reflect.Array {
+		converted, err := d.convertKey(key, indexType)
+		if err != nil {
+			return fail(err)
+		}
+		index, ok := resolveIndex(converted.(int), c.Len())
+		if !ok {
+			return fail(fmt.Errorf("%w: %d is beyond length %d", ErrIndexOutOfRange, converted, c.Len()))
+		}
+		return p.append(nodes.ByKey[strconv.Itoa(index)]), nil
+	}
+
+	for _, dynamic := range nodes.InOrder {
+		if !dynamic.IsDynamic() {
+			continue
+		}
+		converted, err := d.convertKey(key, dynamic.KeyType)
+		if err != nil {
+			return fail(err)
+		}
+		return p.append(dynamic.ForKey(converted)), nil
+	}
+
+	return fail(fmt.Errorf("%w: %v has no '%v'", ErrUnknownKey, rt, key))
+}
+
+// Returns the path following each key or a *PathError for the first key
+// which isn't valid.
+func (p Path) TryNexts(keys ...any) (Path, error) {
+	return defaultDecoder.Load().tryNexts(p, keys)
+}
+
+// Returns the path following each key, decoding string keys with this decoder.
+func (d *Decoder) tryNexts(p Path, keys []any) (Path, error) {
+	var err error
+	for _, key := range keys {
+		if p, err = d.tryNext(p, key); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// Returns a *PathError for the first step of the path which can't be set,
+// or can't be gotten to set what's after it.
+func (p Path) CheckSet() error {
+	last := len(p.nodes) - 1
+	for i, node := range p.nodes {
+		var err error
+		if node.Set == nil {
+			err = ErrSetNotSupported
+		} else if node.Get == nil && i < last {
+			err = ErrGetNotSupported
+		}
+		if err != nil {
+			before := Path{root: p.root, nodes: p.nodes[:i]}
+			return &PathError{Path: before, Step: i, Key: node.Key, Err: fmt.Errorf("%w: %s is read-only", err, node.KeyString)}
+		}
+	}
+	return nil
+}
+
+// Returns a reference following the key or a *PathError explaining why the
+// key isn't valid. See Path.TryNext.
+func (r Ref) TryNext(key any) (*Ref, error) {
+	path, err := r.path.TryNext(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.at(path), nil
+}
+
+// Returns a reference following each key or a *PathError for the first key
+// which isn't valid.
+func (r Ref) TryNexts(keys ...any) (*Ref, error) {
+	path, err := r.path.TryNexts(keys...)
+	if err != nil {
+		return nil, err
+	}
+	return r.at(path), nil
+}
+
+// Converts the key to the key type. Strings are decoded and integers are
+// converted to other integer types.
+func (d *Decoder) convertKey(key any, kt reflect.Type) (any, error) {
+	rk := Reflect(key)
+	switch {
+	case kt == nil:
+		return key, nil
+	case !rk.IsValid():
+		return nil, fmt.Errorf("%w: nil is not %v", ErrKeyType, kt)
+	case rk.Type().AssignableTo(kt):
+		return key, nil
+	case rk.Kind() == reflect.String:
+		decoded, err := d.DecodeType(kt, rk.String())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKeyType, err)
+		}
+		return decoded, nil
+	case isIntegerKind(rk.Kind()) && isIntegerKind(kt.Kind()):
+		return rk.Convert(kt).Interface(), nil
+	}
+	return nil, fmt.Errorf("%w: %v is not %v", ErrKeyType, rk.Type(), kt)
+}
@@ -0,0 +1,136 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestBuildPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []any
+		set      bool
+		expected string
+		err      error
+		step     int
+		valid    string
+	}{{
+		name:     "fields and keys",
+		keys:     []any{"ByName", "John", "Name", "First"},
+		expected: "ByName[John].Name.First",
+	}, {
+		name:     "decoded key",
+		keys:     []any{"ByID", "5", "X"},
+		expected: "ByID[5].X",
+	}, {
+		name:     "converted key",
+		keys:     []any{"ByID", int64(5)},
+		expected: "ByID[5]",
+	}, {
+		name:     "negative array index",
+		keys:     []any{"Pair", -1},
+		expected: "Pair[1]",
+	}, {
+		name:  "unknown field",
+		keys:  []any{"ByName", "John", "Age"},
+		err:   ErrUnknownKey,
+		step:  2,
+		valid: "Name",
+	}, {
+		name: "wrong key type",
+		keys: []any{"ByID", "x"},
+		err:  ErrKeyType,
+		step: 1,
+	}, {
+		name: "wrong index type",
+		keys: []any{"Points", 1.5},
+		err:  ErrKeyType,
+		step: 1,
+	}, {
+		name: "beyond array",
+		keys: []any{"Pair", 2},
+		err:  ErrIndexOutOfRange,
+		step: 1,
+	}, {
+		name:     "getter",
+		keys:     []any{"Points", 0, "Sum"},
+		expected: "Points[0].Sum",
+	}, {
+		name: "read-only set",
+		keys: []any{"Points", 0, "Sum"},
+		set:  true,
+		err:  ErrSetNotSupported,
+		step: 2,
+	}}
+
+	rt := reflect.TypeOf(pathRoot{})
+	for _, test := range tests {
+		build := BuildPath
+		if test.set {
+			build = BuildSetPath
+		}
+		path, err := build(rt, test.keys...)
+		if test.err != nil {
+			pathErr := &PathError{}
+			if !errors.Is(err, test.err) || !errors.As(err, &pathErr) {
+				t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+				continue
+			}
+			if pathErr.Step != test.step {
+				t.Errorf("[%s] expected step %d but got %d", test.name, test.step, pathErr.Step)
+			}
+			if test.valid != "" && !StringEqual(pathErr.Keys, []string{test.valid}) {
+				t.Errorf("[%s] expected valid keys %v but got %v", test.name, test.valid, pathErr.Keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if path.String() != test.expected {
+			t.Errorf("[%s] expected %s but got %s", test.name, test.expected, path.String())
+		}
+	}
+}
+
+func TestDecoderBuildPath(t *testing.T) {
+	rt := reflect.TypeOf(pathRoot{})
+	hex := DefaultDecoder().With(WithInt(func(s string, bits int) (int64, error) {
+		return strconv.ParseInt(s, 16, bits)
+	}))
+
+	path, err := hex.BuildPath(rt, "ByID", "ff")
+	if err != nil || path.String() != "ByID[255]" {
+		t.Errorf("expected the key decoded by the decoder but got %v %v", path, err)
+	}
+	if _, err := BuildPath(rt, "ByID", "ff"); !errors.Is(err, ErrKeyType) {
+		t.Errorf("expected the default decoder to fail but got %v", err)
+	}
+}
+
+func TestRefTryNexts(t *testing.T) {
+	root := &pathRoot{}
+	ref := NewRef(root)
+
+	if ref.Nexts([]any{"Missing", "X"}) != nil {
+		t.Errorf("expected nil for an unknown key")
+	}
+	if _, err := ref.TryNexts("ByName", "John", "Missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected an unknown key but got %v", err)
+	}
+
+	next, err := ref.TryNexts("Points", 0, "X")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := next.Set(3); err != nil || len(root.Points) != 1 || root.Points[0].X != 3 {
+		t.Errorf("expected the point set but got %v %+v", err, root.Points)
+	}
+
+	if nodes := ref.Next("ByName").Next("John").NextNodes(); nodes == nil || len(nodes.InOrder) == 0 {
+		t.Errorf("expected the nodes of a missing value to come from its type")
+	}
+}
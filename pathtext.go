@@ -0,0 +1,318 @@
+package refstr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The path string could not be parsed or does not exist on the type.
+var ErrInvalidPath = errors.New("invalid path")
+
+// Parses a path like ByName[John].Name.First starting at the root type with
+// the default decoder.
+func ParsePath(root reflect.Type, s string) (Path, error) {
+	return defaultDecoder.Load().ParsePath(root, s)
+}
+
+// Parses a path like ByName[John].Name.First starting at the root type.
+// Fields and methods are separated by dots, map keys and slice or array
+// indices are in brackets. Map keys are decoded into the key type of the map
+// with this decoder. Names and keys which contain special characters can be
+// double quoted. Negative indices are from the end of a slice or array, [+]
+// appends to a slice when set and [1:3] is a range of elements which can be
+// set to a slice or a single value for each element. A * matches every field,
+// key or index and .. matches a value and everything inside of it. Filters
+// like [?Age>=18] match the elements where the comparison on the sub-path is
+// true and filters like [/^J/] match the keys with the regex. Paths with these
+// are expanded against a value, though paths with filters which match a
+// single value can also be gotten and set.
+func (d Decoder) ParsePath(root reflect.Type, s string) (Path, error) {
+	return d.parsePath(NewPath(root), s)
+}
+
+// Returns the string form of the path which can be parsed by ParsePath with
+// this decoder.
+func (d Decoder) FormatPath(p Path) string {
+	sb := &strings.Builder{}
+	for i, node := range p.nodes {
+		d.formatNode(sb, node, i > 0 && p.nodes[i-1].Kind != NodeDescendant)
+	}
+	return sb.String()
+}
+
+// Returns the string form of the path which can be parsed by ParsePath.
+func (p Path) String() string {
+	return defaultDecoder.Load().FormatPath(p)
+}
+
+// Returns a reference to the inner value at the given path string relative
+// to this reference.
+func (r Ref) NextPath(s string) (*Ref, error) {
+	next, err := defaultDecoder.Load().parsePath(r.path, s)
+	if err != nil {
+		return nil, err
+	}
+	return r.at(next), nil
+}
+
+// A name or key in a path string.
+type pathToken struct {
+	text      string
+	bracketed bool
+	quoted    bool
+	descend   bool
+	filter    bool
+	offset    int
+}
+
+// Returns whether the token is a wildcard.
+func (t pathToken) isWildcard() bool {
+	return t.text == "*" && !t.quoted
+}
+
+// Parses the path string and appends its nodes to the given path.
+func (d *Decoder) parsePath(start Path, s string) (Path, error) {
+	tokens, err := scanPath(s)
+	if err != nil {
+		return start, err
+	}
+	p := start
+	for _, token := range tokens {
+		node, err := d.resolveToken(p, token)
+		if err != nil {
+			return start, fmt.Errorf("%w: %s at %d in '%s'", ErrInvalidPath, err.Error(), token.offset, s)
+		}
+		p = p.append(node)
+	}
+	return p, nil
+}
+
+// Returns the node at the end of the path the token refers to. When the type
+// at the end of the path isn't known the token is looked up on the value once
+// the path is expanded.
+func (d *Decoder) resolveToken(p Path, token pathToken) (Node, error) {
+	switch {
+	case token.descend:
+		return descendantNode(), nil
+	case token.filter:
+		if p.Type() != nil && ConcreteType(p.Type()).Kind() == reflect.Interface {
+			return d.filterNode(nil, token.text)
+		}
+		return d.filterNode(p.Type(), token.text)
+	case p.Type() == nil || ConcreteType(p.Type()).Kind() == reflect.Interface:
+		return d.lookupNode(token), nil
+	case token.isWildcard():
+		return wildcardNode(p.Type()), nil
+	case token.bracketed && !token.quoted:
+		if node, ok, err := resolveSliceToken(p.Type(), &token); ok || err != nil {
+			return node, err
+		}
+	}
+	return d.resolveNode(p.NextNodes(), p.Type(), token)
+}
+
+// Returns the node of the given nodes the token refers to. Bracketed tokens
+// prefer map keys and indices, dotted tokens prefer fields & methods.
+func (d *Decoder) resolveNode(nodes *Nodes, rt reflect.Type, token pathToken) (Node, error) {
+	named, isNamed := nodes.ByKey[token.text]
+	isNamed = isNamed && !named.IsDynamic()
+
+	if isNamed && !token.bracketed {
+		return named, nil
+	}
+	for _, node := range nodes.InOrder {
+		if node.IsDynamic() {
+			key, err := d.DecodeType(node.KeyType, token.text)
+			if err != nil {
+				return node, fmt.Errorf("key '%s' is not a %v", token.text, node.KeyType)
+			}
+			return node.ForKey(key), nil
+		}
+	}
+	if isNamed {
+		return named, nil
+	}
+	return Node{}, fmt.Errorf("%v has no '%s'", rt, token.text)
+}
+
+// Returns the append or range node of a slice or array the token refers to.
+// Negative array indices are changed to the index they refer to since the
+// length of an array is known.
+func resolveSliceToken(rt reflect.Type, token *pathToken) (Node, bool, error) {
+	c := ConcreteType(rt)
+	if c.Kind() != reflect.Slice && c.Kind() != reflect.Array {
+		return Node{}, false, nil
+	}
+	switch {
+	case token.text == "+":
+		if c.Kind() == reflect.Array {
+			return Node{}, false, fmt.Errorf("can't append to %v", rt)
+		}
+		return appendNode(c), true, nil
+	case strings.Contains(token.text, ":"):
+		r, err := parseSliceRange(token.text)
+		return rangeNode(c, r), err == nil, err
+	case c.Kind() == reflect.Array:
+		if index, err := strconv.Atoi(token.text); err == nil && index < 0 && index >= -c.Len() {
+			token.text = strconv.Itoa(c.Len() + index)
+		}
+	}
+	return Node{}, false, nil
+}
+
+// Returns a node which looks up the token on the value it's expanded against.
+// Values without the name or key are not matched.
+func (d *Decoder) lookupNode(token pathToken) Node {
+	if token.isWildcard() {
+		return wildcardNode(nil)
+	}
+	node := Node{
+		Key:       token.text,
+		KeyString: token.text,
+		Kind:      NodeLookup,
+	}
+	if !token.bracketed {
+		node.KeyType = fieldType
+	}
+	node.Expand = func(n Node, rv reflect.Value) [][]Node {
+		inner := rv
+		for inner.Kind() == reflect.Interface || (inner.Kind() == reflect.Pointer && IsPointing(inner.Elem())) {
+			inner = inner.Elem()
+		}
+		if !Concrete(inner).IsValid() {
+			return nil
+		}
+		found, err := d.resolveNode(GetTypeNodes(inner.Type()), inner.Type(), token)
+		if err != nil || !hasNode(found, rv) || (found.Kind == NodeMethod && inner.Type() != rv.Type()) {
+			return nil
+		}
+		return [][]Node{{found}}
+	}
+	return node
+}
+
+// Splits the path string into names and keys.
+func scanPath(s string) ([]pathToken, error) {
+	tokens := make([]pathToken, 0)
+	expectName := true
+	for i := 0; i < len(s); {
+		token := pathToken{offset: i}
+		var err error
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			token.descend = true
+			i += 2
+		case strings.HasPrefix(s[i:], "[?") || strings.HasPrefix(s[i:], "[/"):
+			token.bracketed = true
+			token.filter = true
+			token.text, i, err = scanPathFilter(s, i+1)
+			if err == nil && (i >= len(s) || s[i] != ']') {
+				err = errors.New("missing ]")
+			}
+			i++
+		case s[i] == '[':
+			token.bracketed = true
+			token.text, i, token.quoted, err = scanPathText(s, i+1, "]")
+			if err == nil && (i >= len(s) || s[i] != ']') {
+				err = errors.New("missing ]")
+			}
+			i++
+		case s[i] == '.' || expectName:
+			if s[i] == '.' {
+				i++
+			}
+			token.text, i, token.quoted, err = scanPathText(s, i, ".[")
+			if err == nil && token.text == "" && !token.quoted {
+				err = errors.New("missing name")
+			}
+		default:
+			err = fmt.Errorf("unexpected '%c'", s[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s at %d in '%s'", ErrInvalidPath, err.Error(), token.offset, s)
+		}
+		expectName = token.descend
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// Reads a double quoted string or the text up to any of the stop characters
+// starting at i, returning the text, the index after it and whether it was quoted.
+func scanPathText(s string, i int, stop string) (string, int, bool, error) {
+	if i < len(s) && s[i] == '"' {
+		end := i + 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return "", end, true, errors.New("missing closing quote")
+		}
+		text, err := strconv.Unquote(s[i : end+1])
+		return text, end + 1, true, err
+	}
+	end := i
+	for end < len(s) && !strings.ContainsRune(stop, rune(s[end])) {
+		end++
+	}
+	return s[i:end], end, false, nil
+}
+
+// Writes the node to the path string, names are preceded by a dot if dotted.
+func (d *Decoder) formatNode(sb *strings.Builder, node Node, dotted bool) {
+	switch {
+	case node.Kind == NodeDescendant:
+		sb.WriteString("..")
+	case node.Kind == NodeIndex || node.Kind == NodeAppend || node.Kind == NodeRange:
+		sb.WriteString("[" + node.KeyString + "]")
+	case node.Kind == NodeMapKey:
+		key, err := d.encode(Reflect(node.Key))
+		if err != nil {
+			key = node.KeyString
+		}
+		sb.WriteString("[" + quotePathText(key) + "]")
+	case node.Kind == NodeFilter:
+		sb.WriteString("[" + node.KeyString + "]")
+	case node.Kind == NodeWildcard && node.Type != nil:
+		sb.WriteString("[*]")
+	case node.Kind == NodeLookup && node.KeyType == nil:
+		sb.WriteString("[" + quotePathText(node.KeyString) + "]")
+	default:
+		if dotted {
+			sb.WriteString(".")
+		}
+		if node.Kind == NodeWildcard {
+			sb.WriteString("*")
+		} else {
+			sb.WriteString(quotePathText(node.KeyString))
+		}
+	}
+}
+
+// Quotes the text if it would not be read back as is.
+func quotePathText(text string) string {
+	if isPathName(text) {
+		return text
+	}
+	return strconv.Quote(text)
+}
+
+// Returns whether the text only has letters, digits, underscores and dashes.
+func isPathName(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package refstr
+
+import (
+	"errors"
+	"testing"
+)
+
+type pathKey struct{ A, B int }
+
+type pathRoot struct {
+	ByName  map[string]person
+	ByID    map[int]*point
+	ByPoint map[pathKey]string
+	Points  []point
+	Pair    [2]string
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		keys      []any
+		formatted string
+		err       error
+	}{{
+		name: "empty",
+		path: "",
+		keys: []any{},
+	}, {
+		name: "fields",
+		path: "ByName[John].Name.First",
+		keys: []any{"ByName", "John", "Name", "First"},
+	}, {
+		name: "method",
+		path: "ByName[John].Name.Full",
+		keys: []any{"ByName", "John", "Name", "Full"},
+	}, {
+		name:      "leading dot",
+		path:      ".Points[2].X",
+		keys:      []any{"Points", 2, "X"},
+		formatted: "Points[2].X",
+	}, {
+		name: "int key",
+		path: "ByID[42].Sum",
+		keys: []any{"ByID", 42, "Sum"},
+	}, {
+		name: "struct key",
+		path: `ByPoint["{A:1 B:2}"]`,
+		keys: []any{"ByPoint", pathKey{A: 1, B: 2}},
+	}, {
+		name: "quoted key",
+		path: `ByName["John Doe.Jr [1]"].Name`,
+		keys: []any{"ByName", "John Doe.Jr [1]", "Name"},
+	}, {
+		name: "empty key",
+		path: `ByName[""]`,
+		keys: []any{"ByName", ""},
+	}, {
+		name:      "unquoted key",
+		path:      "ByName[John Doe].Name",
+		keys:      []any{"ByName", "John Doe", "Name"},
+		formatted: `ByName["John Doe"].Name`,
+	}, {
+		name:      "dotted map key",
+		path:      "ByName.John",
+		keys:      []any{"ByName", "John"},
+		formatted: "ByName[John]",
+	}, {
+		name: "array",
+		path: "Pair[1]",
+		keys: []any{"Pair", 1},
+	}, {
+		name: "unknown field",
+		path: "ByName[John].Age",
+		err:  ErrInvalidPath,
+	}, {
+		name: "invalid key",
+		path: "ByID[x]",
+		err:  ErrInvalidPath,
+	}, {
+		name: "negative index",
+		path: "Points[-1].X",
+		keys: []any{"Points", -1, "X"},
+	}, {
+		name:      "negative array index",
+		path:      "Pair[-1]",
+		keys:      []any{"Pair", 1},
+		formatted: "Pair[1]",
+	}, {
+		name: "append",
+		path: "Points[+].X",
+		keys: []any{"Points", "+", "X"},
+	}, {
+		name: "range",
+		path: "Points[1:-1]",
+		keys: []any{"Points", "1:-1"},
+	}, {
+		name: "open range",
+		path: "Pair[:1][0]",
+		keys: []any{"Pair", ":1", 0},
+	}, {
+		name: "quoted plus",
+		path: `ByName["+"]`,
+		keys: []any{"ByName", "+"},
+	}, {
+		name: "array append",
+		path: "Pair[+]",
+		err:  ErrInvalidPath,
+	}, {
+		name: "invalid range",
+		path: "Points[a:2]",
+		err:  ErrInvalidPath,
+	}, {
+		name: "array negative out of bounds",
+		path: "Pair[-3]",
+		err:  ErrInvalidPath,
+	}, {
+		name: "array out of bounds",
+		path: "Pair[2]",
+		err:  ErrInvalidPath,
+	}, {
+		name: "missing bracket",
+		path: "ByName[John",
+		err:  ErrInvalidPath,
+	}, {
+		name: "missing quote",
+		path: `ByName["John]`,
+		err:  ErrInvalidPath,
+	}, {
+		name: "missing name",
+		path: "ByName.",
+		err:  ErrInvalidPath,
+	}, {
+		name: "unexpected text",
+		path: `ByName["John"]Name`,
+		err:  ErrInvalidPath,
+	}}
+
+	for _, test := range tests {
+		path, err := ParsePath(TypeOf[pathRoot](), test.path)
+		if !errors.Is(err, test.err) {
+			t.Errorf("[%s] expected error %v but got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !StringEqual(path.Keys(), test.keys) {
+			t.Errorf("[%s] expected keys %v but got %v", test.name, test.keys, path.Keys())
+		}
+		formatted := test.formatted
+		if formatted == "" {
+			formatted = test.path
+		}
+		if path.String() != formatted {
+			t.Errorf("[%s] expected string %s but got %s", test.name, formatted, path.String())
+		}
+		reparsed, err := ParsePath(TypeOf[pathRoot](), path.String())
+		if err != nil || !StringEqual(reparsed.Keys(), path.Keys()) {
+			t.Errorf("[%s] expected %s to parse back to %v but got %v %v", test.name, path.String(), path.Keys(), reparsed.Keys(), err)
+		}
+	}
+}
+
+func TestRefNextPath(t *testing.T) {
+	root := pathRoot{}
+	john, err := NewRef(&root).NextPath(`ByName["John Doe"].Name`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	first, err := john.NextPath("First")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := first.Set("John"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if root.ByName["John Doe"].Name.First != "John" {
+		t.Errorf("expected first name to be set but got %v", root.ByName)
+	}
+	if first.Path().String() != `ByName["John Doe"].Name.First` {
+		t.Errorf("unexpected path %s", first.Path().String())
+	}
+}
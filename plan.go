@@ -0,0 +1,429 @@
+package refstr
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var textUnmarshalerType = TypeOf[encoding.TextUnmarshaler]()
+var byteType = TypeOf[byte]()
+
+// A compiled plan for decoding a string into a specific type. A plan resolves
+// everything about a type that does not depend on the string being decoded
+// (pointer depth, unmarshalling, fields & element plans) so it only has to be
+// done once per type.
+type decodePlan struct {
+	typ           reflect.Type
+	concrete      reflect.Type
+	kind          reflect.Kind
+	pointers      int
+	unmarshal     bool
+	unmarshalAddr bool
+	bits          int
+	bytes         bool
+	byteArray     bool
+	set           bool
+	member        reflect.Value
+	elem          *decodePlan
+	key           *decodePlan
+	fields        map[string]fieldPlan
+}
+
+// A struct field that can be decoded.
+type fieldPlan struct {
+	index []int
+	plan  *decodePlan
+}
+
+// A cache of compiled plans. Plans only depend on the types they decode, the
+// parsers and enums are looked up as values are decoded, so copies of a
+// decoder can share them.
+type planCache struct {
+	plans sync.Map
+}
+
+// Discards all compiled plans.
+func (c *planCache) reset() {
+	c.plans.Range(func(key, _ any) bool {
+		c.plans.Delete(key)
+		return true
+	})
+}
+
+// Returns the plan for decoding the given type, compiling it if needed.
+func (d *Decoder) plan(rt reflect.Type) *decodePlan {
+	if d.plans == nil {
+		return d.compilePlan(rt, nil, make(map[reflect.Type]*decodePlan))
+	}
+	if cached, ok := d.plans.plans.Load(rt); ok {
+		return cached.(*decodePlan)
+	}
+	building := make(map[reflect.Type]*decodePlan)
+	plan := d.compilePlan(rt, d.plans, building)
+	for t, p := range building {
+		d.plans.plans.LoadOrStore(t, p)
+	}
+	return plan
+}
+
+// Returns the custom parser for the plan type. It's looked up as values are
+// decoded so changes to the decoder's parsers apply right away.
+func (p *decodePlan) parser(d *Decoder) Parser {
+	return d.Parsers[p.concrete]
+}
+
+// Returns the named values of the plan type, looked up like parser.
+func (p *decodePlan) enum(d *Decoder) []EnumValue {
+	return d.Enums[p.concrete]
+}
+
+// Compiles the plan for the given type. Plans in the middle of being built
+// are tracked so recursive types reuse the same plan.
+func (d *Decoder) compilePlan(rt reflect.Type, cache *planCache, building map[reflect.Type]*decodePlan) *decodePlan {
+	if p, ok := building[rt]; ok {
+		return p
+	}
+	if cache != nil {
+		if cached, ok := cache.plans.Load(rt); ok {
+			return cached.(*decodePlan)
+		}
+	}
+
+	p := &decodePlan{typ: rt}
+	building[rt] = p
+
+	c := rt
+	for c.Kind() == reflect.Pointer {
+		c = c.Elem()
+		p.pointers++
+	}
+	p.concrete = c
+	p.kind = c.Kind()
+	p.bits = kindBits[p.kind]
+
+	switch {
+	case p.pointers == 0:
+		// Values are settable so unmarshalers on the pointer can be used too.
+		p.unmarshal = c.Implements(textUnmarshalerType)
+		p.unmarshalAddr = !p.unmarshal && reflect.PointerTo(c).Implements(textUnmarshalerType)
+		p.unmarshal = p.unmarshal || p.unmarshalAddr
+	case p.pointers <= 2:
+		p.unmarshal = reflect.PointerTo(c).Implements(textUnmarshalerType)
+		p.unmarshalAddr = true
+	}
+
+	switch p.kind {
+	case reflect.Array:
+		p.byteArray = c.Elem() == byteType
+		p.elem = d.compilePlan(c.Elem(), cache, building)
+	case reflect.Slice:
+		p.bytes = isBytes(c)
+		p.elem = d.compilePlan(c.Elem(), cache, building)
+	case reflect.Map:
+		p.set = isSetMap(c)
+		if p.set {
+			p.member = setMember(c.Elem())
+		}
+		p.key = d.compilePlan(c.Key(), cache, building)
+		p.elem = d.compilePlan(c.Elem(), cache, building)
+	case reflect.Struct:
+		p.fields = make(map[string]fieldPlan)
+		for _, f := range reflect.VisibleFields(c) {
+			if !f.IsExported() {
+				continue
+			}
+			field, ok := c.FieldByName(f.Name)
+			if !ok {
+				continue
+			}
+			p.fields[f.Name] = fieldPlan{
+				index: field.Index,
+				plan:  d.compilePlan(field.Type, cache, building),
+			}
+		}
+	}
+
+	return p
+}
+
+// Decodes the string into dst which must be a settable value of the plan type.
+func (p *decodePlan) decode(d *Decoder, s string, dst reflect.Value) error {
+	for i := 0; i < p.pointers; i++ {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	if p.unmarshal {
+		target := dst
+		if p.unmarshalAddr {
+			target = dst.Addr()
+		}
+		err := target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		if err != nil {
+			return fmt.Errorf("error unmarshalling text '%s': %w", s, err)
+		}
+		return nil
+	}
+
+	if parser := p.parser(d); parser != nil {
+		parsed, err := parser(s)
+		if err != nil {
+			return fmt.Errorf("error with custom parsing '%s': %w", s, err)
+		}
+		dst.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	if enum := p.enum(d); enum != nil {
+		if err := validateEnum(p.concrete, enum); err != nil {
+			return err
+		}
+		for _, e := range enum {
+			if strings.EqualFold(e.Name, s) {
+				dst.Set(reflect.ValueOf(e.Value).Convert(p.concrete))
+				return nil
+			}
+		}
+	}
+
+	switch p.kind {
+	case reflect.Bool:
+		lower := strings.ToLower(s)
+		if _, isTrue := d.Trues[lower]; isTrue {
+			dst.SetBool(true)
+			return nil
+		}
+		if _, isFalse := d.Falses[lower]; isFalse {
+			dst.SetBool(false)
+			return nil
+		}
+		return fmt.Errorf("error parsing '%s' as bool", s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if p.kind == reflect.Int32 && d.Chars != CharsOff {
+			if r, ok, err := d.decodeChar(s, false); ok {
+				if err != nil {
+					return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+				}
+				dst.SetInt(int64(r))
+				return nil
+			}
+		}
+		parsed, err := d.Int(s, p.bits)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+		}
+		dst.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if p.kind == reflect.Uint8 && d.Chars != CharsOff {
+			if r, ok, err := d.decodeChar(s, true); ok {
+				if err != nil {
+					return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+				}
+				dst.SetUint(uint64(r))
+				return nil
+			}
+		}
+		parsed, err := d.Uint(s, p.bits)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+		}
+		dst.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := d.Float(s, p.bits)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+		}
+		dst.SetFloat(parsed)
+	case reflect.Complex64, reflect.Complex128:
+		parsed, err := d.Complex(s, p.bits)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.kind, err)
+		}
+		dst.SetComplex(parsed)
+	case reflect.String:
+		dst.SetString(s)
+	case reflect.Array:
+		if p.byteArray && d.Bytes != BytesRaw {
+			bytes, err := d.decodeBytes(s)
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+			}
+			if len(bytes) != dst.Len() {
+				return fmt.Errorf("error parsing '%s' as %v: expected %d bytes but got %d", s, p.concrete, dst.Len(), len(bytes))
+			}
+			reflect.Copy(dst, reflect.ValueOf(bytes))
+			return nil
+		}
+
+		elements, err := d.Array.Values(s, p.concrete.Len())
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+		}
+		for i, elementString := range elements {
+			err := p.elem.decode(d, elementString, dst.Index(i))
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' as %v: %w", elementString, p.elem.typ, err)
+			}
+		}
+	case reflect.Slice:
+		if p.bytes {
+			bytes, err := d.decodeBytes(s)
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+			}
+			dst.SetBytes(bytes)
+			return nil
+		}
+
+		elements, err := d.Slice.Values(s, -1)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+		}
+		if d.Ranges && p.elem.isInteger(d) {
+			return p.decodeRanges(d, elements, dst)
+		}
+		dst.Set(reflect.MakeSlice(p.concrete, len(elements), len(elements)))
+		for i, elementString := range elements {
+			err := p.elem.decode(d, elementString, dst.Index(i))
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' as %v: %w", elementString, p.elem.typ, err)
+			}
+		}
+	case reflect.Map:
+		if p.set {
+			return p.decodeSet(d, s, dst)
+		}
+
+		keyValues, err := d.Map.KeyValues(s, -1)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(p.concrete, len(keyValues)))
+		}
+		key := reflect.New(p.key.typ).Elem()
+		value := reflect.New(p.elem.typ).Elem()
+		zeroKey := reflect.Zero(p.key.typ)
+		zeroValue := reflect.Zero(p.elem.typ)
+
+		for _, keyValue := range keyValues {
+			key.Set(zeroKey)
+			err := p.key.decode(d, keyValue[0], key)
+			if err != nil {
+				return fmt.Errorf("error parsing map key '%s' as %v: %w", keyValue[0], p.key.typ, err)
+			}
+			value.Set(zeroValue)
+			err = p.elem.decode(d, keyValue[1], value)
+			if err != nil {
+				return fmt.Errorf("error parsing map value '%s' as %v: %w", keyValue[1], p.elem.typ, err)
+			}
+			dst.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		keyValues, err := d.Struct.KeyValues(s, -1)
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+		}
+
+		for _, keyValue := range keyValues {
+			fieldName := keyValue[0]
+			field, exists := p.fields[fieldName]
+			if !exists {
+				return fmt.Errorf("error parsing '%s', unknown field '%s'", s, fieldName)
+			}
+			fieldValue := fieldByIndex(dst, field.index)
+			fieldValue.Set(reflect.Zero(field.plan.typ))
+			err := field.plan.decode(d, keyValue[1], fieldValue)
+			if err != nil {
+				return fmt.Errorf("error parsing struct field '%s' with value '%s' as %v: %w", fieldName, keyValue[1], field.plan.typ, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported kind %v", p.concrete)
+	}
+
+	return nil
+}
+
+// Decodes a set-like map which accepts the list syntax of slices, the key-value
+// syntax of maps, or a mix where entries without a value are members.
+func (p *decodePlan) decodeSet(d *Decoder, s string, dst reflect.Value) error {
+	var entries []string
+	var err error
+	keyed := strings.HasPrefix(s, d.Map.Start)
+	if keyed {
+		entries, err = d.Map.Values(s, -1)
+	} else {
+		entries, err = d.Slice.Values(s, -1)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing '%s' as %v: %w", s, p.concrete, err)
+	}
+	if len(entries) == 1 && strings.TrimSpace(entries[0]) == "" {
+		entries = nil
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(p.concrete, len(entries)))
+	}
+	key := reflect.New(p.key.typ).Elem()
+	value := reflect.New(p.elem.typ).Elem()
+	zeroKey := reflect.Zero(p.key.typ)
+
+	for _, entry := range entries {
+		keyString, valueString := entry, ""
+		if keyed && d.Map.KeySeparator != nil {
+			if keyValue := d.Map.KeySeparator.Split(entry, 2); len(keyValue) == 2 {
+				keyString, valueString = keyValue[0], keyValue[1]
+			}
+		}
+		key.Set(zeroKey)
+		err := p.key.decode(d, keyString, key)
+		if err != nil {
+			return fmt.Errorf("error parsing map key '%s' as %v: %w", keyString, p.key.typ, err)
+		}
+		value.Set(p.member)
+		if valueString != "" && p.elem.kind == reflect.Bool {
+			err = p.elem.decode(d, valueString, value)
+			if err != nil {
+				return fmt.Errorf("error parsing map value '%s' as %v: %w", valueString, p.elem.typ, err)
+			}
+		}
+		dst.SetMapIndex(key, value)
+	}
+	return nil
+}
+
+// Returns whether the given map type is used as a set, where the values are
+// bools or empty structs.
+func isSetMap(rt reflect.Type) bool {
+	elem := rt.Elem()
+	return elem.Kind() == reflect.Bool || (elem.Kind() == reflect.Struct && elem.NumField() == 0)
+}
+
+// Returns the value which marks a key as a member of a set-like map.
+func setMember(elem reflect.Type) reflect.Value {
+	if elem.Kind() == reflect.Bool {
+		return reflect.ValueOf(true).Convert(elem)
+	}
+	return reflect.Zero(elem)
+}
+
+// Returns the field at the given index, initializing any nil embedded
+// pointers along the way.
+func fieldByIndex(rv reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv
+}
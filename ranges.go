@@ -0,0 +1,177 @@
+package refstr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// Matches a range of integers with an optional step: 1-5, 1..5, 0-10/2
+var rangeRegex = regexp.MustCompile(`^\s*(-?\d+)\s*(?:-|\.\.)\s*(-?\d+)\s*(?:/\s*(\d+))?\s*$`)
+
+// A range of integers or a single element of a slice.
+type integerRange struct {
+	from, to, step int64
+	count          int
+	element        string
+	isRange        bool
+}
+
+// Returns whether the plan decodes directly into an integer.
+func (p *decodePlan) isInteger(d *Decoder) bool {
+	return p.pointers == 0 && !p.unmarshal && p.parser(d) == nil && isIntegerKind(p.kind)
+}
+
+// Returns whether the kind is a signed or unsigned integer.
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// Decodes the elements of an integer slice where elements can be ranges.
+func (p *decodePlan) decodeRanges(d *Decoder, elements []string, dst reflect.Value) error {
+	ranges := make([]integerRange, len(elements))
+	total, expanded := 0, 0
+	for i, element := range elements {
+		r, err := parseRange(element)
+		if err != nil {
+			return err
+		}
+		ranges[i] = r
+		total += r.count
+		if r.isRange {
+			expanded += r.count
+		}
+		if d.MaxRange > 0 && expanded > d.MaxRange {
+			return fmt.Errorf("error parsing '%s', ranges expand beyond the maximum of %d elements", element, d.MaxRange)
+		}
+	}
+
+	slice := reflect.MakeSlice(p.concrete, total, total)
+	dst.Set(slice)
+	unsigned := p.elem.kind >= reflect.Uint && p.elem.kind <= reflect.Uintptr
+
+	i := 0
+	for _, r := range ranges {
+		if !r.isRange {
+			err := p.elem.decode(d, r.element, slice.Index(i))
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' as %v: %w", r.element, p.elem.typ, err)
+			}
+			i++
+			continue
+		}
+		for k, n := 0, r.from; k < r.count; k, n = k+1, n+r.step {
+			el := slice.Index(i)
+			if unsigned {
+				if n < 0 || el.OverflowUint(uint64(n)) {
+					return fmt.Errorf("error parsing '%s' as %v: %d out of range", r.element, p.elem.typ, n)
+				}
+				el.SetUint(uint64(n))
+			} else {
+				if el.OverflowInt(n) {
+					return fmt.Errorf("error parsing '%s' as %v: %d out of range", r.element, p.elem.typ, n)
+				}
+				el.SetInt(n)
+			}
+			i++
+		}
+	}
+	return nil
+}
+
+// Parses the element as a range if it is one.
+func parseRange(element string) (integerRange, error) {
+	match := rangeRegex.FindStringSubmatch(element)
+	if match == nil {
+		return integerRange{element: element, count: 1}, nil
+	}
+	r := integerRange{element: element, isRange: true, step: 1}
+	var err error
+	if r.from, err = strconv.ParseInt(match[1], 10, 64); err != nil {
+		return r, fmt.Errorf("error parsing range '%s': %w", element, err)
+	}
+	if r.to, err = strconv.ParseInt(match[2], 10, 64); err != nil {
+		return r, fmt.Errorf("error parsing range '%s': %w", element, err)
+	}
+	if match[3] != "" {
+		if r.step, err = strconv.ParseInt(match[3], 10, 64); err != nil || r.step == 0 {
+			return r, fmt.Errorf("error parsing range '%s', invalid step", element)
+		}
+	}
+	distance := uint64(r.to - r.from)
+	if r.to < r.from {
+		distance = uint64(r.from - r.to)
+		r.step = -r.step
+	}
+	count := distance/uint64(abs(r.step)) + 1
+	if count > math.MaxInt32 {
+		return r, fmt.Errorf("error parsing range '%s', too many elements", element)
+	}
+	r.count = int(count)
+	return r, nil
+}
+
+// Encodes an integer slice, writing runs of three or more consecutive
+// integers as ranges.
+func (d *Decoder) encodeRanges(rv reflect.Value) (string, error) {
+	n := rv.Len()
+	unsigned := rv.Type().Elem().Kind() >= reflect.Uint
+	value := func(i int) (int64, bool) {
+		if unsigned {
+			u := rv.Index(i).Uint()
+			return int64(u), u <= math.MaxInt64
+		}
+		return rv.Index(i).Int(), true
+	}
+
+	values := make([]string, 0, n)
+	for i := 0; i < n; {
+		from, ok := value(i)
+		end := i
+		for ok && end+1 < n {
+			next, nextOk := value(end + 1)
+			if !nextOk || next != from+int64(end+1-i) {
+				break
+			}
+			end++
+		}
+		if end-i >= 2 {
+			to, _ := value(end)
+			separator := "-"
+			if from < 0 || to < 0 {
+				separator = ".."
+			}
+			values = append(values, strconv.FormatInt(from, 10)+separator+strconv.FormatInt(to, 10))
+			i = end + 1
+			continue
+		}
+		element, err := d.encode(rv.Index(i))
+		if err != nil {
+			return "", err
+		}
+		values = append(values, element)
+		i++
+	}
+	return d.Slice.Join(values), nil
+}
+
+// Returns the absolute value of the integer.
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Returns whether the elements of the slice type can be encoded as ranges.
+func isRangeSlice(rt reflect.Type) bool {
+	elem := rt.Elem()
+	return isIntegerKind(elem.Kind()) && !elem.Implements(textMarshalerType)
+}
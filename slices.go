@@ -0,0 +1,201 @@
+package refstr
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A range of elements of a slice or array like 1:3. Negative bounds are from
+// the end and missing bounds are the start or end of the elements.
+type sliceRange struct {
+	start, end       int
+	hasStart, hasEnd bool
+}
+
+// Parses a range like 1:3, :3, 1: or -2:.
+func parseSliceRange(s string) (sliceRange, error) {
+	startText, endText, _ := strings.Cut(s, ":")
+	r := sliceRange{}
+	var err error
+	if startText = strings.TrimSpace(startText); startText != "" {
+		r.hasStart = true
+		if r.start, err = strconv.Atoi(startText); err != nil {
+			return r, fmt.Errorf("invalid range start '%s'", startText)
+		}
+	}
+	if endText = strings.TrimSpace(endText); endText != "" {
+		r.hasEnd = true
+		if r.end, err = strconv.Atoi(endText); err != nil {
+			return r, fmt.Errorf("invalid range end '%s'", endText)
+		}
+	}
+	return r, nil
+}
+
+// Returns the range as it's written in a path.
+func (r sliceRange) String() string {
+	sb := strings.Builder{}
+	if r.hasStart {
+		sb.WriteString(strconv.Itoa(r.start))
+	}
+	sb.WriteString(":")
+	if r.hasEnd {
+		sb.WriteString(strconv.Itoa(r.end))
+	}
+	return sb.String()
+}
+
+// Returns the start and end of the range for a length and whether they're in range.
+func (r sliceRange) resolve(length int) (int, int, bool) {
+	start, end := 0, length
+	if r.hasStart {
+		start = r.start
+		if start < 0 {
+			start += length
+		}
+	}
+	if r.hasEnd {
+		end = r.end
+		if end < 0 {
+			end += length
+		}
+	}
+	return start, end, start >= 0 && start <= end && end <= length
+}
+
+// Returns a node for the range of elements of the slice or array type. Only
+// ranges of slices can be deleted.
+func rangeNode(rt reflect.Type, r sliceRange) Node {
+	node := Node{
+		Key:       r,
+		KeyString: r.String(),
+		KeyType:   indexType,
+		Type:      reflect.SliceOf(ConcreteType(rt).Elem()),
+		Kind:      NodeRange,
+		Get:       rangeGet,
+		Set:       rangeSet,
+	}
+	if ConcreteType(rt).Kind() == reflect.Slice {
+		node.Delete = rangeDelete
+	}
+	return node
+}
+
+// Returns a node which appends to the slice type when set.
+func appendNode(rt reflect.Type) Node {
+	return Node{
+		Key:       "+",
+		KeyString: "+",
+		KeyType:   indexType,
+		Type:      ConcreteType(rt).Elem(),
+		Kind:      NodeAppend,
+		Get:       appendGet,
+		Set:       appendSet,
+		Insert:    appendSet,
+	}
+}
+
+var rangeGet NodeGet = func(n Node, rv reflect.Value) reflect.Value {
+	c := Concrete(rv)
+	start, end, ok := n.Key.(sliceRange).resolve(c.Len())
+	if !ok {
+		return invalidValue
+	}
+	if c.Kind() == reflect.Array && !c.CanAddr() {
+		c = PointerTo(c).Elem()
+	}
+	return c.Slice(start, end)
+}
+
+// Sets the range to the elements of a slice or array, or sets each element
+// in the range to a single value. A slice with a different number of elements
+// replaces the range, growing or shrinking the slice.
+var rangeSet NodeSet = func(n Node, rv, val reflect.Value) error {
+	c := Concrete(rv)
+	start, end, ok := n.Key.(sliceRange).resolve(c.Len())
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	elem := c.Type().Elem()
+	values := Concrete(val)
+
+	if (values.Kind() == reflect.Slice || values.Kind() == reflect.Array) && values.Type().Elem().AssignableTo(elem) {
+		if values.Len() != end-start {
+			if c.Kind() != reflect.Slice || !c.CanSet() {
+				return ErrSetNotSupported
+			}
+			spliced := reflect.MakeSlice(c.Type(), 0, c.Len()-(end-start)+values.Len())
+			spliced = reflect.AppendSlice(spliced, c.Slice(0, start))
+			for i := 0; i < values.Len(); i++ {
+				spliced = reflect.Append(spliced, values.Index(i))
+			}
+			spliced = reflect.AppendSlice(spliced, c.Slice(end, c.Len()))
+			c.Set(spliced)
+			return nil
+		}
+		snapshot := reflect.MakeSlice(reflect.SliceOf(values.Type().Elem()), values.Len(), values.Len())
+		reflect.Copy(snapshot, values)
+		for i := start; i < end; i++ {
+			el := c.Index(i)
+			if !el.CanSet() {
+				return ErrSetNotSupported
+			}
+			el.Set(snapshot.Index(i - start))
+		}
+		return nil
+	}
+
+	if !val.IsValid() || !val.Type().AssignableTo(elem) {
+		return ErrSetNotSupported
+	}
+	for i := start; i < end; i++ {
+		el := c.Index(i)
+		if !el.CanSet() {
+			return ErrSetNotSupported
+		}
+		el.Set(val)
+	}
+	return nil
+}
+
+// Removes the range of elements from a slice.
+var rangeDelete NodeDelete = func(n Node, rv reflect.Value) error {
+	c := Concrete(rv)
+	if c.Kind() != reflect.Slice || !c.CanSet() {
+		return ErrDeleteNotSupported
+	}
+	start, end, ok := n.Key.(sliceRange).resolve(c.Len())
+	if !ok {
+		return errPathMissing
+	}
+	removeElements(c, start, end)
+	return nil
+}
+
+var appendGet NodeGet = func(n Node, rv reflect.Value) reflect.Value {
+	return invalidValue
+}
+
+var appendSet NodeSet = func(n Node, rv, val reflect.Value) error {
+	c := Concrete(rv)
+	if c.Kind() != reflect.Slice || !c.CanSet() {
+		return ErrSetNotSupported
+	}
+	c.Set(reflect.Append(c, val))
+	return nil
+}
+
+// Removes the elements from start to end of the settable slice by shifting the
+// elements after them down, zeroing the elements left at the end.
+func removeElements(c reflect.Value, start, end int) {
+	length := c.Len()
+	reflect.Copy(c.Slice(start, length), c.Slice(end, length))
+	remaining := length - (end - start)
+	zero := reflect.Zero(c.Type().Elem())
+	for i := remaining; i < length; i++ {
+		c.Index(i).Set(zero)
+	}
+	c.Set(c.Slice(0, remaining))
+}
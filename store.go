@@ -0,0 +1,492 @@
+package refstr
+
+import (
+	"reflect"
+	"sync"
+)
+
+// The kind of change made to a value in a store.
+type ChangeKind int
+
+const (
+	// A value was set where there wasn't one, like a new map key or slice element.
+	ChangeAdded ChangeKind = iota
+	// A map key or slice element was removed.
+	ChangeRemoved
+	// An existing value was changed.
+	ChangeModified
+)
+
+// A change made to a value in a store. Old is invalid for added values and
+// New is invalid for removed values. Slices and maps in Old and New are
+// copies, the values inside of them are not.
+type Change struct {
+	Kind ChangeKind
+	Path Path
+	Old  reflect.Value
+	New  reflect.Value
+
+	// How to remove an added value and what it created along the way: the path
+	// to delete, or the path to set back to undoValue when it's valid.
+	undoPath  Path
+	undoValue reflect.Value
+	// Whether the value was inserted before an existing element.
+	inserted bool
+}
+
+// A value which notifies subscribers of the changes made through its
+// references. Changes are made one at a time and subscribers are notified of
+// them in the order they were made, each in the order they subscribed.
+type Store struct {
+	root          reflect.Value
+	lock          sync.Mutex
+	pending       []notification
+	notifying     bool
+	subscribers   []*subscription
+	subscribeLock sync.Mutex
+}
+
+// A change waiting to be sent to the subscribers.
+type notification struct {
+	change Change
+	skip   *subscription
+}
+
+// A function notified of the changes that overlap a path.
+type subscription struct {
+	prefix Path
+	notify func(Change)
+}
+
+// Creates a store for the given value, which needs to be a pointer for
+// changes to be made.
+func NewStore(v any) *Store {
+	return &Store{root: Reflect(v)}
+}
+
+// Returns a reference to the root of the store. Changes made through this
+// reference and the references that follow from it notify subscribers.
+func (s *Store) Ref() Ref {
+	ref := NewRef(s.root)
+	ref.store = s
+	return ref
+}
+
+// Calls fn with every change made to a value at, inside or containing the
+// value at the prefix path. The prefix can have wildcards, filters and
+// recursive descent which match any key. Changes are delivered after they're
+// made by the goroutine which made them, unless another goroutine is already
+// delivering changes in which case it delivers them in order. Changes made by
+// fn are delivered after fn returns. The returned func stops the notifications.
+func (s *Store) Subscribe(prefix string, fn func(Change)) (func(), error) {
+	path, err := ParsePath(s.root.Type(), prefix)
+	if err != nil {
+		return nil, err
+	}
+	sub := s.subscribe(path, fn)
+
+	return func() { s.unsubscribe(sub) }, nil
+}
+
+// Sends every change made to a value at, inside or containing the value at
+// the prefix path to the returned channel with the given buffer size. The
+// channel needs to be read from or the delivery of changes will block once
+// it's full. The returned func stops the notifications, even when blocked on
+// a full channel, and closes the channel.
+func (s *Store) SubscribeChan(prefix string, size int) (<-chan Change, func(), error) {
+	changes := make(chan Change, size)
+	done := make(chan struct{})
+	closed := false
+	sending := sync.RWMutex{}
+
+	unsubscribe, err := s.Subscribe(prefix, func(c Change) {
+		sending.RLock()
+		defer sending.RUnlock()
+		if closed {
+			return
+		}
+		select {
+		case changes <- c:
+		case <-done:
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	once := sync.Once{}
+	return changes, func() {
+		once.Do(func() {
+			unsubscribe()
+			close(done)
+			sending.Lock()
+			defer sending.Unlock()
+			closed = true
+			close(changes)
+		})
+	}, nil
+}
+
+// Adds a subscription for the changes that overlap the prefix.
+func (s *Store) subscribe(prefix Path, fn func(Change)) *subscription {
+	sub := &subscription{prefix: prefix, notify: fn}
+
+	s.subscribeLock.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.subscribeLock.Unlock()
+
+	return sub
+}
+
+// Removes the subscription.
+func (s *Store) unsubscribe(sub *subscription) {
+	s.subscribeLock.Lock()
+	defer s.subscribeLock.Unlock()
+
+	for i, existing := range s.subscribers {
+		if existing == sub {
+			s.subscribers = append(s.subscribers[:i:i], s.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Makes changes with the lock held and then notifies the subscribers of them,
+// so the changes made by one call can't be interleaved with another's.
+func (s *Store) write(changes func() (*Change, error)) error {
+	s.lock.Lock()
+	_, err := changes()
+	s.lock.Unlock()
+	s.flush()
+	return err
+}
+
+// Queues the change for the subscribers, they're notified once the lock is
+// released. The lock needs to be held.
+func (s *Store) queue(change Change, skip *subscription) {
+	s.pending = append(s.pending, notification{change: change, skip: skip})
+}
+
+// Notifies the subscribers of the queued changes in the order they were made,
+// unless they're already being notified by another call.
+func (s *Store) flush() {
+	s.lock.Lock()
+	if s.notifying {
+		s.lock.Unlock()
+		return
+	}
+	s.notifying = true
+	finished := false
+	defer func() {
+		// A subscriber panicked, let the next change notify the rest.
+		if !finished {
+			s.lock.Lock()
+			s.notifying = false
+			s.lock.Unlock()
+		}
+	}()
+
+	for len(s.pending) > 0 {
+		next := s.pending[0]
+		s.pending = s.pending[1:]
+		s.lock.Unlock()
+		s.notify(next.change, next.skip)
+		s.lock.Lock()
+	}
+	s.notifying = false
+	finished = true
+	s.lock.Unlock()
+}
+
+// Notifies the subscribers whose prefix overlaps the changed path, except
+// for the skipped subscription which made the change.
+func (s *Store) notify(change Change, skip *subscription) {
+	s.subscribeLock.Lock()
+	subscribers := s.subscribers
+	s.subscribeLock.Unlock()
+
+	for _, sub := range subscribers {
+		if sub != skip && sub.prefix.overlaps(change.Path) {
+			sub.notify(change)
+		}
+	}
+}
+
+// Sets the value at the path and queues the change for subscribers, returning
+// it. Like the other changes below, the lock needs to be held.
+func (s *Store) set(p Path, val any, skip *subscription) (*Change, error) {
+	path := s.concrete(p)
+	old, err := path.Get(s.root)
+	existed := err == nil
+	old = snapshot(old)
+
+	var undoPath Path
+	var undoValue reflect.Value
+	if !existed {
+		undoPath, undoValue = s.created(path)
+	}
+
+	target := s.root
+	if p.IsEmpty() && target.Kind() == reflect.Pointer && !target.IsNil() {
+		target = target.Elem()
+	}
+	if err := p.Set(target, val); err != nil {
+		return nil, err
+	}
+
+	change := Change{Kind: ChangeModified, Path: path, Old: old, New: s.current(path, val)}
+	if !existed {
+		change.Kind = ChangeAdded
+		change.Old = invalidValue
+		change.undoPath = undoPath
+		change.undoValue = undoValue
+	}
+	s.queue(change, skip)
+	return &change, nil
+}
+
+// Deletes the value at the path and queues the change if it existed,
+// returning the change or nil if there wasn't one.
+func (s *Store) delete(p Path, skip *subscription) (*Change, error) {
+	path := s.concrete(p)
+	old, err := path.Get(s.root)
+	if err != nil {
+		return nil, p.Delete(s.root)
+	}
+	old = snapshot(old)
+
+	if err := p.Delete(s.root); err != nil {
+		return nil, err
+	}
+
+	change := Change{Kind: ChangeRemoved, Path: path, Old: old}
+	if end := p.End(); end == nil || (end.Kind != NodeMapKey && end.Kind != NodeIndex && end.Kind != NodeRange) {
+		change.Kind = ChangeModified
+		change.New = s.current(path, nil)
+	}
+	s.queue(change, skip)
+	return &change, nil
+}
+
+// Inserts the value at the path and queues the change, returning it.
+func (s *Store) insert(p Path, val any, skip *subscription) (*Change, error) {
+	path := s.concrete(p)
+
+	if err := p.Insert(s.root, val); err != nil {
+		return nil, err
+	}
+
+	change := Change{Kind: ChangeAdded, Path: path, New: s.current(path, val), inserted: true}
+	s.queue(change, skip)
+	return &change, nil
+}
+
+// Moves the element at the path and queues the change to the slice or array
+// it's in, returning the change.
+func (s *Store) move(p Path, to int, skip *subscription) (*Change, error) {
+	parent := s.concrete(p)
+	if !parent.IsEmpty() {
+		parent = parent.parent()
+	}
+	old, _ := parent.Get(s.root)
+	old = snapshot(old)
+
+	if err := p.Move(s.root, to); err != nil {
+		return nil, err
+	}
+
+	change := Change{Kind: ChangeModified, Path: parent, Old: old, New: s.current(parent, nil)}
+	s.queue(change, skip)
+	return &change, nil
+}
+
+// Sets the value at the path in place when the concrete value there has the
+// same type, otherwise sets the path. Used to restore values which were
+// pointed to.
+func (s *Store) reset(p Path, val reflect.Value, skip *subscription) (*Change, error) {
+	if current, err := p.Get(s.root); err == nil {
+		if c := Concrete(current); c.IsValid() && c.CanSet() && c.Type() == val.Type() {
+			change := Change{Kind: ChangeModified, Path: s.concrete(p), Old: snapshot(c)}
+			c.Set(val)
+			change.New = snapshot(c)
+			s.queue(change, skip)
+			return &change, nil
+		}
+	}
+	return s.set(p, val, skip)
+}
+
+// Returns the path with filters, negative indices, appends and ranges changed
+// to the keys and indices they refer to in the current value.
+func (s *Store) concrete(p Path) Path {
+	path := NewPath(p.root)
+	for _, node := range p.nodes {
+		if node.Kind == NodeFilter {
+			if value, err := path.Get(s.root); err == nil {
+				if matches := node.Expand(node, value); len(matches) == 1 && len(matches[0]) == 1 {
+					node = matches[0][0]
+				}
+			}
+			path = path.append(node)
+			continue
+		}
+		if r, ok := node.Key.(sliceRange); ok && node.Kind == NodeRange {
+			if value, err := path.Get(s.root); err == nil {
+				c := Concrete(value)
+				if c.Kind() == reflect.Slice || c.Kind() == reflect.Array {
+					if start, end, ok := r.resolve(c.Len()); ok {
+						node = rangeNode(c.Type(), sliceRange{start: start, end: end, hasStart: true, hasEnd: true})
+					}
+				}
+			}
+			path = path.append(node)
+			continue
+		}
+		index, negative := node.Key.(int)
+		negative = negative && index < 0 && node.Kind == NodeIndex
+		if negative || node.Kind == NodeAppend {
+			length := 0
+			if value, err := path.Get(s.root); err == nil {
+				if c := Concrete(value); c.Kind() == reflect.Slice || c.Kind() == reflect.Array {
+					length = c.Len()
+				}
+			}
+			if node.Kind == NodeAppend {
+				index = 0
+			}
+			if next := path.Next(length + index); next != nil {
+				path = *next
+				continue
+			}
+		}
+		path = path.append(node)
+	}
+	return path
+}
+
+// Returns how to remove a value about to be set at the path along with
+// anything setting it creates. That's the first missing map key, the elements
+// a slice grows by, or the value containing them when it's nil or can't be
+// gotten from.
+func (s *Store) created(p Path) (Path, reflect.Value) {
+	for i := 1; i <= len(p.nodes); i++ {
+		prefix := Path{root: p.root, nodes: p.nodes[:i]}
+		if _, err := prefix.Get(s.root); err == nil {
+			continue
+		}
+		parent := prefix.parent()
+		value, _ := parent.Get(s.root)
+		c := Concrete(value)
+		empty := !c.IsValid() || ((c.Kind() == reflect.Map || c.Kind() == reflect.Slice) && c.IsNil())
+
+		switch node := p.nodes[i-1]; {
+		case empty && !parent.IsEmpty():
+			return parent, snapshot(value)
+		case node.Kind == NodeMapKey:
+			return prefix, invalidValue
+		case node.Kind == NodeIndex && c.Kind() == reflect.Slice:
+			return parent.append(rangeNode(c.Type(), sliceRange{start: c.Len(), hasStart: true})), invalidValue
+		}
+		return parent, snapshot(c)
+	}
+	return p, invalidValue
+}
+
+// Returns a copy of the current value at the path or the value that was set
+// if the path can't be gotten.
+func (s *Store) current(p Path, val any) reflect.Value {
+	current, err := p.Get(s.root)
+	if err != nil {
+		return Reflect(val)
+	}
+	return snapshot(current)
+}
+
+// Returns the path without its last node.
+func (p Path) parent() Path {
+	return Path{root: p.root, nodes: p.nodes[:len(p.nodes)-1]}
+}
+
+// Returns whether one path is a prefix of the other. Wildcards, filters and
+// ranges match any key and recursive descent matches any number of keys.
+func (p Path) overlaps(other Path) bool {
+	return nodesOverlap(p.nodes, other.nodes)
+}
+
+// Returns whether one list of nodes is a prefix of the other.
+func nodesOverlap(a, b []Node) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if a[0].Kind == NodeDescendant {
+			a, b = b, a
+		}
+		if b[0].Kind == NodeDescendant {
+			for i := 0; i <= len(a); i++ {
+				if nodesOverlap(a[i:], b[1:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if !matchesAnyKey(a[0]) && !matchesAnyKey(b[0]) && a[0].KeyString != b[0].KeyString {
+			return false
+		}
+		a, b = a[1:], b[1:]
+	}
+	return true
+}
+
+// Returns whether the node could match any key when deciding whether paths overlap.
+func matchesAnyKey(n Node) bool {
+	return n.IsPattern() || n.Kind == NodeRange
+}
+
+// Gets the value at the path while no changes are being made.
+func (s *Store) value(p Path) (reflect.Value, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return p.Get(s.root)
+}
+
+// Gets every value the path matches while no changes are being made.
+func (s *Store) values(p Path) ([]Match, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return p.GetAll(s.root)
+}
+
+// Returns the concrete paths the path matches while no changes are being made.
+func (s *Store) expand(p Path) []Path {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return p.Expand(s.root)
+}
+
+// Returns a copy of the value which isn't changed when the value is changed
+// in place. Slices and maps are copied but the values inside them are not.
+func snapshot(rv reflect.Value) reflect.Value {
+	if !rv.IsValid() || !rv.CanInterface() {
+		return rv
+	}
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return reflect.Zero(rv.Type())
+		}
+		copied := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(copied, rv)
+		return copied
+	case reflect.Map:
+		if rv.IsNil() {
+			return reflect.Zero(rv.Type())
+		}
+		copied := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return copied
+	}
+	copied := reflect.New(rv.Type()).Elem()
+	copied.Set(rv)
+	return copied
+}
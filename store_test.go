@@ -0,0 +1,315 @@
+package refstr
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		change    func(ref Ref) error
+		kind      ChangeKind
+		path      string
+		old       any
+		new       any
+		notNotify bool
+	}{{
+		name:   "modified field",
+		prefix: "Server",
+		change: func(ref Ref) error { return ref.Next("Server").Next("Port").Set(8080) },
+		kind:   ChangeModified,
+		path:   "Server.Port",
+		old:    80,
+		new:    8080,
+	}, {
+		name:   "containing value",
+		prefix: "Server.Port",
+		change: func(ref Ref) error { return ref.Next("Server").Set(testServer{Port: 1}) },
+		kind:   ChangeModified,
+		path:   "Server",
+		old:    testServer{Host: "a", Port: 80},
+		new:    testServer{Port: 1},
+	}, {
+		name:      "other prefix",
+		prefix:    "Name",
+		change:    func(ref Ref) error { return ref.Next("Server").Next("Port").Set(8080) },
+		notNotify: true,
+	}, {
+		name:   "set string",
+		prefix: "",
+		change: func(ref Ref) error { return ref.Next("Server").Next("Port").SetString("90") },
+		kind:   ChangeModified,
+		path:   "Server.Port",
+		old:    80,
+		new:    90,
+	}, {
+		name:   "added map key",
+		prefix: "Limits",
+		change: func(ref Ref) error { return ref.Next("Limits").Next("memory").Set(512) },
+		kind:   ChangeAdded,
+		path:   "Limits[memory]",
+		new:    512,
+	}, {
+		name:   "removed map key",
+		prefix: "Limits[cpu]",
+		change: func(ref Ref) error { return ref.Next("Limits").Next("cpu").Delete() },
+		kind:   ChangeRemoved,
+		path:   "Limits[cpu]",
+		old:    2,
+	}, {
+		name:      "removed missing map key",
+		prefix:    "Limits",
+		change:    func(ref Ref) error { return ref.Next("Limits").Next("disk").Delete() },
+		notNotify: true,
+	}, {
+		name:   "deleted field",
+		prefix: "Name",
+		change: func(ref Ref) error { return ref.Next("Name").Delete() },
+		kind:   ChangeModified,
+		path:   "Name",
+		old:    "config",
+		new:    "",
+	}, {
+		name:   "appended",
+		prefix: "Servers[*].Host",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[+].Host")
+			return next.Set("c")
+		},
+		kind: ChangeAdded,
+		path: "Servers[2].Host",
+		new:  "c",
+	}, {
+		name:   "negative index",
+		prefix: "Servers[1]",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[-1].Port")
+			return next.Set(2)
+		},
+		kind: ChangeModified,
+		path: "Servers[1].Port",
+		old:  0,
+		new:  2,
+	}, {
+		name:   "recursive descent prefix",
+		prefix: "..Port",
+		change: func(ref Ref) error { return ref.Next("Server").Next("Port").Set(8080) },
+		kind:   ChangeModified,
+		path:   "Server.Port",
+		old:    80,
+		new:    8080,
+	}, {
+		name:   "filter prefix",
+		prefix: "Servers[?Host==b].Port",
+		change: func(ref Ref) error { return ref.Next("Servers").Next(1).Next("Port").Set(2) },
+		kind:   ChangeModified,
+		path:   "Servers[1].Port",
+		old:    0,
+		new:    2,
+	}, {
+		name:      "other recursive descent prefix",
+		prefix:    "Servers..Host",
+		change:    func(ref Ref) error { return ref.Next("Server").Next("Port").Set(8080) },
+		notNotify: true,
+	}, {
+		name:   "inserted",
+		prefix: "Servers",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[0]")
+			return next.Insert(testServer{Host: "z"})
+		},
+		kind: ChangeAdded,
+		path: "Servers[0]",
+		new:  testServer{Host: "z"},
+	}, {
+		name:   "moved",
+		prefix: "Servers[0]",
+		change: func(ref Ref) error {
+			next, _ := ref.NextPath("Servers[0]")
+			return next.Move(1)
+		},
+		kind: ChangeModified,
+		path: "Servers",
+		old:  []testServer{{Host: "a"}, {Host: "b"}},
+		new:  []testServer{{Host: "b"}, {Host: "a"}},
+	}}
+
+	for _, test := range tests {
+		config := &testConfig{
+			Name:    "config",
+			Server:  testServer{Host: "a", Port: 80},
+			Servers: []testServer{{Host: "a"}, {Host: "b"}},
+			Limits:  map[string]int{"cpu": 2},
+		}
+		store := NewStore(config)
+		changes := make([]Change, 0)
+		_, err := store.Subscribe(test.prefix, func(c Change) {
+			changes = append(changes, c)
+		})
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if err := test.change(store.Ref()); err != nil {
+			t.Errorf("[%s] unexpected error %v", test.name, err)
+			continue
+		}
+		if test.notNotify {
+			if len(changes) != 0 {
+				t.Errorf("[%s] expected no changes but got %v", test.name, changes)
+			}
+			continue
+		}
+		if len(changes) != 1 {
+			t.Errorf("[%s] expected 1 change but got %v", test.name, changes)
+			continue
+		}
+		change := changes[0]
+		if change.Kind != test.kind || change.Path.String() != test.path {
+			t.Errorf("[%s] expected %v at %s but got %v at %s", test.name, test.kind, test.path, change.Kind, change.Path.String())
+		}
+		if (test.old == nil) == change.Old.IsValid() || (test.old != nil && !StringEqual(change.Old.Interface(), test.old)) {
+			t.Errorf("[%s] expected old value %v but got %v", test.name, test.old, change.Old)
+		}
+		if (test.new == nil) == change.New.IsValid() || (test.new != nil && !StringEqual(change.New.Interface(), test.new)) {
+			t.Errorf("[%s] expected new value %v but got %v", test.name, test.new, change.New)
+		}
+	}
+}
+
+func TestStoreSubscriptions(t *testing.T) {
+	config := &testConfig{Servers: []testServer{{Port: 1}, {Port: 2}}}
+	store := NewStore(config)
+
+	ports := make([]string, 0)
+	unsubscribe, _ := store.Subscribe("Servers[*].Port", func(c Change) {
+		ports = append(ports, c.Path.String())
+	})
+	changes, cancel, _ := store.SubscribeChan("Servers", 10)
+
+	all, _ := store.Ref().NextPath("Servers[*].Port")
+	if _, err := all.SetAll(8080); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !StringEqual(ports, []string{"Servers[0].Port", "Servers[1].Port"}) {
+		t.Errorf("unexpected changes %v", ports)
+	}
+
+	unsubscribe()
+	store.Ref().Next("Servers").Next(0).Next("Port").Set(1)
+	if len(ports) != 2 {
+		t.Errorf("expected no changes after unsubscribing but got %v", ports)
+	}
+
+	cancel()
+	received := make([]string, 0)
+	for c := range changes {
+		received = append(received, c.Path.String())
+	}
+	if !StringEqual(received, []string{"Servers[0].Port", "Servers[1].Port", "Servers[0].Port"}) {
+		t.Errorf("unexpected channel changes %v", received)
+	}
+
+	if _, err := store.Subscribe("Missing", func(c Change) {}); err == nil {
+		t.Errorf("expected invalid prefix error")
+	}
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	config := &testConfig{Limits: map[string]int{}}
+	store := NewStore(config)
+	changes, cancel, _ := store.SubscribeChan("Limits", 0)
+
+	received := 0
+	done := make(chan struct{})
+	go func() {
+		for range changes {
+			received++
+		}
+		close(done)
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				store.Ref().Next("Limits").Next(ToString(i)).Set(j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	cancel()
+	<-done
+
+	if received != 400 {
+		t.Errorf("expected 400 changes but got %d", received)
+	}
+}
+
+func TestStoreChanCancel(t *testing.T) {
+	store := NewStore(&testConfig{})
+	_, cancel, _ := store.SubscribeChan("", 0)
+
+	set := make(chan error)
+	go func() {
+		set <- store.Ref().Next("Name").Set("blocked")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelled := make(chan struct{})
+	go func() {
+		cancel()
+		close(cancelled)
+	}()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected cancel to return while a change is blocked on the channel")
+	}
+	select {
+	case err := <-set:
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the blocked change to return after cancelling")
+	}
+}
+
+func TestStoreOrder(t *testing.T) {
+	config := &testConfig{}
+	store := NewStore(config)
+	changes := make([]Change, 0)
+	store.Subscribe("Name", func(c Change) {
+		changes = append(changes, c)
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				store.Ref().Next("Name").Set(ToString(i*50 + j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(changes) != 400 {
+		t.Fatalf("expected 400 changes but got %d", len(changes))
+	}
+	for i := 1; i < len(changes); i++ {
+		if changes[i].Old.Interface() != changes[i-1].New.Interface() {
+			t.Fatalf("expected changes in the order they were made but %v followed %v", changes[i], changes[i-1])
+		}
+	}
+	if changes[len(changes)-1].New.Interface() != config.Name {
+		t.Errorf("expected the last change to be the current value %s", config.Name)
+	}
+}
@@ -0,0 +1,447 @@
+package refstr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Commit was called on a transaction which was already committed.
+var ErrTxDone = errors.New("transaction already committed")
+
+// The value given to a transaction can't be set to the type of its path.
+var ErrInvalidValue = errors.New("value can't be set to the path type")
+
+// A value a transaction replaces, removes, moves, copies or tests doesn't exist.
+var ErrValueMissing = errors.New("value does not exist")
+
+// A value a transaction tests is different from the expected value.
+var ErrTestFailed = errors.New("value is different than expected")
+
+// A set or delete of a transaction which is invalid or failed to apply.
+type TxError struct {
+	// The index of the set or delete in the transaction.
+	Index int
+	Path  string
+	Err   error
+	// The error restoring the original state after a failure, if any.
+	Rollback error
+}
+
+// Returns the failed operation and why.
+func (e TxError) Error() string {
+	if e.Rollback != nil {
+		return fmt.Sprintf("operation %d at '%s': %v (rollback failed: %v)", e.Index, e.Path, e.Err, e.Rollback)
+	}
+	return fmt.Sprintf("operation %d at '%s': %v", e.Index, e.Path, e.Err)
+}
+
+// Returns the error of the operation.
+func (e TxError) Unwrap() error {
+	return e.Err
+}
+
+// The sets and deletes of a transaction which failed validation.
+type TxErrors []TxError
+
+// Returns a summary of the invalid operations.
+func (e TxErrors) Error() string {
+	failures := make([]string, len(e))
+	for i, err := range e {
+		failures[i] = err.Error()
+	}
+	return fmt.Sprintf("%d operations invalid: %s", len(e), strings.Join(failures, "; "))
+}
+
+// Returns whether any of the invalid operations has the target error.
+func (e TxErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets and deletes which are validated up front and applied together. If
+// any of them fails, every value they touched is restored.
+type Tx struct {
+	store *Store
+	ops   []txOp
+	done  bool
+}
+
+// What an operation in a transaction does.
+type txKind int
+
+const (
+	// Sets the value, creating what's missing.
+	txSet txKind = iota
+	// Deletes the value if it exists.
+	txDelete
+	// Inserts into a slice at an index or sets anything else.
+	txAdd
+	// Sets an existing value.
+	txReplace
+	// Deletes an existing value.
+	txRemove
+	// Removes the existing value at from and adds it at the path.
+	txMove
+	// Adds a copy of the existing value at from at the path.
+	txCopy
+	// Checks that the existing value equals the value.
+	txTest
+)
+
+// A set, delete or other operation in a transaction.
+type txOp struct {
+	kind     txKind
+	path     Path
+	text     string
+	from     Path
+	fromText string
+	value    reflect.Value
+	decode   bool
+	decoder  *Decoder
+	err      error
+}
+
+// Begins a transaction on the value, which needs to be a pointer.
+func BeginTx(root any) *Tx {
+	return NewStore(root).BeginTx()
+}
+
+// Begins a transaction on the store. Subscribers are notified of each change
+// it applied and of the changes made to restore values on failure once it's
+// committed.
+func (s *Store) BeginTx() *Tx {
+	return &Tx{store: s}
+}
+
+// Adds setting the value at the path.
+func (tx *Tx) Set(path string, val any) *Tx {
+	return tx.add(path, txOp{value: Reflect(val)})
+}
+
+// Adds setting the value at the path from a string, decoded into the type
+// of the path.
+func (tx *Tx) SetString(path string, s string) *Tx {
+	return tx.add(path, txOp{value: reflect.ValueOf(s), decode: true})
+}
+
+// Adds deleting the value at the path.
+func (tx *Tx) Delete(path string) *Tx {
+	return tx.add(path, txOp{kind: txDelete})
+}
+
+// Adds setting the value at the path.
+func (tx *Tx) SetPath(path Path, val any) *Tx {
+	tx.ops = append(tx.ops, txOp{path: path, text: path.String(), value: Reflect(val)})
+	return tx
+}
+
+// Adds deleting the value at the path.
+func (tx *Tx) DeletePath(path Path) *Tx {
+	tx.ops = append(tx.ops, txOp{kind: txDelete, path: path, text: path.String()})
+	return tx
+}
+
+// Parses the path and adds the operation.
+func (tx *Tx) add(text string, op txOp) *Tx {
+	return tx.addWith(defaultDecoder.Load(), text, op)
+}
+
+// Parses the path and from path of the operation with the decoder and adds it.
+// String values of the operation are decoded with the decoder as well.
+func (tx *Tx) addWith(d *Decoder, text string, op txOp) *Tx {
+	op.text = text
+	op.decoder = d
+	if op.err == nil {
+		op.path, op.err = d.ParsePath(tx.store.root.Type(), text)
+	}
+	if op.err == nil && (op.kind == txMove || op.kind == txCopy) {
+		op.from, op.err = d.ParsePath(tx.store.root.Type(), op.fromText)
+	}
+	tx.ops = append(tx.ops, op)
+	return tx
+}
+
+// Checks that every path exists in the type, supports the operation and that
+// every value can be set to its path. The invalid operations are returned
+// as TxErrors.
+func (tx *Tx) Validate() error {
+	invalid := make(TxErrors, 0)
+	for i := range tx.ops {
+		op := &tx.ops[i]
+		if err := op.validate(); err != nil {
+			invalid = append(invalid, TxError{Index: i, Path: op.text, Err: err})
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// Validates and then applies the operations in order while holding the lock
+// of the store, so no other changes are made in between. If validation fails
+// nothing is applied and TxErrors is returned. If an operation fails the
+// values touched by it and the operations before it are restored and a
+// TxError is returned. Subscribers are notified once the commit is done.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	s := tx.store
+	s.lock.Lock()
+	defer s.flush()
+	defer s.lock.Unlock()
+
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	tx.done = true
+
+	changes := make([]Change, 0, len(tx.ops))
+	for i, op := range tx.ops {
+		restore := s.restorer(op.path)
+		if err := tx.apply(op, &changes); err != nil {
+			failed := TxError{Index: i, Path: op.text, Err: err}
+			failed.Rollback = tx.rollback(restore, changes)
+			return failed
+		}
+	}
+	return nil
+}
+
+// Applies the operation, adding the changes it makes.
+func (tx *Tx) apply(op txOp, changes *[]Change) error {
+	s := tx.store
+	record := func(change *Change, err error) error {
+		if change != nil {
+			*changes = append(*changes, *change)
+		}
+		return err
+	}
+
+	value := op.value
+	switch op.kind {
+	case txSet:
+		return record(s.set(op.path, value, nil))
+	case txDelete:
+		return record(s.delete(op.path, nil))
+	case txReplace, txRemove, txTest:
+		current, err := s.get(op.path)
+		if err != nil {
+			return err
+		}
+		switch op.kind {
+		case txReplace:
+			return record(s.set(op.path, value, nil))
+		case txRemove:
+			return record(s.delete(op.path, nil))
+		}
+		if !equalValues(current, value) {
+			return fmt.Errorf("%w: %v is not %v", ErrTestFailed, current, value)
+		}
+		return nil
+	case txMove, txCopy:
+		current, err := s.get(op.from)
+		if err != nil {
+			return err
+		}
+		value = current
+		if op.kind == txMove {
+			if err := record(s.delete(op.from, nil)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if end := op.path.End(); end != nil && end.Kind == NodeIndex {
+		return record(s.insert(op.path, value, nil))
+	}
+	return record(s.set(op.path, value, nil))
+}
+
+// Restores the value touched by a failed operation and undoes the changes
+// before it, returning the first error.
+func (tx *Tx) rollback(restore Change, changes []Change) error {
+	s := tx.store
+	first := s.restore(restore)
+	for i := len(changes) - 1; i >= 0; i-- {
+		if _, err := s.undo(changes[i], nil); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Checks the operation and decodes or converts its value for its path.
+func (op *txOp) validate() error {
+	if op.err != nil {
+		return op.err
+	}
+	switch op.kind {
+	case txTest:
+		return op.validateValue(false)
+	case txDelete, txRemove:
+		return checkNodes(op.path, true)
+	case txMove, txCopy:
+		if err := checkNodes(op.from, op.kind == txMove); err != nil {
+			return fmt.Errorf("from '%s': %w", op.fromText, err)
+		}
+		if err := checkNodes(op.path, false); err != nil {
+			return err
+		}
+		from, to := op.from.Type(), op.path.End().Type
+		if from != nil && to != nil && !from.AssignableTo(to) {
+			return fmt.Errorf("%w: %v to %v", ErrInvalidValue, from, to)
+		}
+		return nil
+	}
+	if err := checkNodes(op.path, false); err != nil {
+		return err
+	}
+	return op.validateValue(true)
+}
+
+// Decodes the value if it's a string to decode and checks that it can be set
+// to the path.
+func (op *txOp) validateValue(set bool) error {
+	if op.path.IsPattern() {
+		return ErrPathPattern
+	}
+	if op.decode {
+		if rt := op.path.Type(); rt != nil {
+			decoded, err := op.decoder.DecodeType(rt, op.value.String())
+			if err != nil {
+				return err
+			}
+			op.value = Reflect(decoded)
+		}
+		op.decode = false
+	}
+	end := op.path.End()
+	if end == nil {
+		if set {
+			return ErrSetNotSupported
+		}
+		return nil
+	}
+	value, err := settable(*end, op.value)
+	if err != nil {
+		return err
+	}
+	op.value = value
+	return nil
+}
+
+// Checks that the path has no wildcards, that values can be gotten and set
+// along it, and that its last node can be set or deleted.
+func checkNodes(p Path, delete bool) error {
+	if p.IsPattern() {
+		return ErrPathPattern
+	}
+	last := len(p.nodes) - 1
+	if last == -1 {
+		if delete {
+			return ErrDeleteNotSupported
+		}
+		return ErrSetNotSupported
+	}
+	for i, node := range p.nodes {
+		if node.Set == nil && (i < last || !delete) {
+			return ErrSetNotSupported
+		}
+		if node.Get == nil && i < last {
+			return ErrGetNotSupported
+		}
+	}
+	if delete && p.nodes[last].Delete == nil {
+		return ErrDeleteNotSupported
+	}
+	return nil
+}
+
+// Returns the value to set to the node, the zero value of its type for nil,
+// or an error if the value can't be set to it.
+func settable(node Node, val reflect.Value) (reflect.Value, error) {
+	rt := node.Type
+	if rt == nil {
+		return val, nil
+	}
+	if !val.IsValid() {
+		switch rt.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(rt), nil
+		}
+		return val, fmt.Errorf("%w: nil to %v", ErrInvalidValue, rt)
+	}
+	if val.Type().AssignableTo(rt) {
+		return val, nil
+	}
+	if node.Kind == NodeRange {
+		elem := rt.Elem()
+		if val.Type().AssignableTo(elem) {
+			return val, nil
+		}
+		if (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && val.Type().Elem().AssignableTo(elem) {
+			return val, nil
+		}
+	}
+	return val, fmt.Errorf("%w: %v to %v", ErrInvalidValue, val.Type(), rt)
+}
+
+// Returns whether the values are deeply equal.
+func equalValues(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if !a.CanInterface() || !b.CanInterface() {
+		return false
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// Returns a copy of the value at the path or ErrValueMissing if it can't be
+// gotten.
+func (s *Store) get(p Path) (reflect.Value, error) {
+	value, err := p.Get(s.root)
+	if err != nil {
+		return value, fmt.Errorf("%w: %v", ErrValueMissing, err)
+	}
+	return snapshot(value), nil
+}
+
+// Returns a change which restores the value at the path if setting or
+// deleting it fails part way.
+func (s *Store) restorer(p Path) Change {
+	path := s.concrete(p)
+	if old, err := path.Get(s.root); err == nil {
+		return Change{Kind: ChangeModified, Path: path, Old: snapshot(old)}
+	}
+	undoPath, undoValue := s.created(path)
+	return Change{Kind: ChangeAdded, Path: path, undoPath: undoPath, undoValue: undoValue}
+}
+
+// Undoes a change made by restorer if the value was changed.
+func (s *Store) restore(c Change) error {
+	path, old := c.Path, c.Old
+	if c.Kind == ChangeAdded {
+		path, old = c.undoPath, c.undoValue
+	}
+	current, err := path.Get(s.root)
+	if !old.IsValid() && (err != nil || (current.Kind() == reflect.Slice && current.Len() == 0)) {
+		return nil
+	}
+	if err == nil && old.IsValid() && current.Type() != old.Type() {
+		current = Concrete(current)
+	}
+	if err == nil && old.IsValid() && current.IsValid() && current.CanInterface() && old.CanInterface() && reflect.DeepEqual(current.Interface(), old.Interface()) {
+		return nil
+	}
+	_, err = s.undo(c, nil)
+	return err
+}
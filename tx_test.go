@@ -0,0 +1,182 @@
+package refstr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type txAccount struct {
+	Name   string
+	Owner  *txOwner
+	Limits map[string]int
+	Items  []int
+	limit  int
+}
+
+type txOwner struct {
+	Email string
+}
+
+func (a *txAccount) SetLimit(limit int) error {
+	a.limit = limit
+	if limit < 0 {
+		return errors.New("negative limit")
+	}
+	return nil
+}
+
+func TestTxCommit(t *testing.T) {
+	account := &txAccount{Name: "a", Limits: map[string]int{"cpu": 1}, Items: []int{1}}
+	err := BeginTx(account).
+		Set("Name", "b").
+		SetString("Limits[memory]", "512").
+		Delete("Limits[cpu]").
+		Set("Items[2]", 3).
+		Set("Owner.Email", "x@y.z").
+		Set("SetLimit", 5).
+		Commit()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := &txAccount{Name: "b", Owner: &txOwner{Email: "x@y.z"}, Limits: map[string]int{"memory": 512}, Items: []int{1, 0, 3}, limit: 5}
+	if !reflect.DeepEqual(account, expected) {
+		t.Errorf("expected %+v but got %+v", expected, account)
+	}
+}
+
+func TestTxValidate(t *testing.T) {
+	account := &txAccount{Name: "a"}
+	tx := BeginTx(account).
+		Set("Name", "b").
+		Set("Name", 1).
+		SetString("Items[0]", "x").
+		Set("Missing", 1).
+		Set("Items[*]", 1)
+
+	err := tx.Commit()
+	failed := TxErrors{}
+	if !errors.As(err, &failed) || len(failed) != 4 {
+		t.Fatalf("expected 4 invalid operations but got %v", err)
+	}
+	if failed[0].Index != 1 || !errors.Is(failed[0].Err, ErrInvalidValue) {
+		t.Errorf("expected an invalid value but got %v", failed[0])
+	}
+	if !errors.Is(err, ErrInvalidPath) || !errors.Is(err, ErrPathPattern) {
+		t.Errorf("expected invalid and pattern paths but got %v", err)
+	}
+	if account.Name != "a" {
+		t.Errorf("expected nothing applied but got %+v", account)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		tx       func(tx *Tx) *Tx
+		notified int
+	}{{
+		name:     "setter error",
+		notified: 9,
+		tx: func(tx *Tx) *Tx {
+			return tx.
+				Set("Name", "b").
+				Set("Limits[memory]", 512).
+				Set("Items[3]", 4).
+				Set("Owner.Email", "x@y.z").
+				Set("SetLimit", -1)
+		},
+	}, {
+		name:     "deletes",
+		notified: 7,
+		tx: func(tx *Tx) *Tx {
+			return tx.
+				Delete("Items[0]").
+				Delete("Limits[cpu]").
+				Delete("Name").
+				Set("SetLimit", -1)
+		},
+	}, {
+		name:     "out of range",
+		notified: 4,
+		tx: func(tx *Tx) *Tx {
+			return tx.
+				Set("Items[-1]", 5).
+				Set("Items[+]", 6).
+				Set("Items[-9]", 1)
+		},
+	}}
+
+	for _, test := range tests {
+		account := &txAccount{Name: "a", Limits: map[string]int{"cpu": 1}, Items: []int{1, 2}, limit: 2}
+		store := NewStore(account)
+		notified := 0
+		store.Subscribe("", func(c Change) { notified++ })
+
+		err := test.tx(store.BeginTx()).Commit()
+		failed := TxError{}
+		if !errors.As(err, &failed) || failed.Rollback != nil {
+			t.Errorf("[%s] expected a failed operation but got %v", test.name, err)
+			continue
+		}
+
+		expected := &txAccount{Name: "a", Limits: map[string]int{"cpu": 1}, Items: []int{1, 2}, limit: 2}
+		if !reflect.DeepEqual(account, expected) {
+			t.Errorf("[%s] expected rollback to %+v but got %+v", test.name, expected, account)
+		}
+		if notified != test.notified {
+			t.Errorf("[%s] expected %d notifications of changes and their rollbacks but got %d", test.name, test.notified, notified)
+		}
+	}
+}
+
+func TestTxRollbackNil(t *testing.T) {
+	tests := []func(tx *Tx) *Tx{
+		func(tx *Tx) *Tx {
+			return tx.Set("Items[+]", 1).Set("Items[-9]", 1)
+		},
+		func(tx *Tx) *Tx {
+			return tx.Set("Items[2]", 3).Set("Limits[cpu]", 2).Set("Items[-9]", 1)
+		},
+		func(tx *Tx) *Tx {
+			return tx.Set("Owner.Email", "x@y.z").Set("Limits[cpu]", 2).Set("SetLimit", -1)
+		},
+	}
+
+	for i, test := range tests {
+		account := &txAccount{}
+		err := test(BeginTx(account)).Commit()
+		failed := TxError{}
+		if !errors.As(err, &failed) || failed.Rollback != nil {
+			t.Errorf("[%d] expected a failed operation but got %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(account, &txAccount{}) {
+			t.Errorf("[%d] expected rollback to nil values but got %#v", i, account)
+		}
+	}
+}
+
+func TestTxDecoder(t *testing.T) {
+	d := DefaultDecoder().With(WithRanges(0))
+	account := &txAccount{}
+	tx := BeginTx(account)
+	tx.addWith(&d, "Items", txOp{value: reflect.ValueOf("[1..3]"), decode: true})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(account.Items, []int{1, 2, 3}) {
+		t.Errorf("expected items decoded with the decoder but got %v", account.Items)
+	}
+}
+
+func TestTxDone(t *testing.T) {
+	tx := BeginTx(&txAccount{}).Set("Name", "a")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected done but got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package refstr
+
+import (
+	"reflect"
+)
+
+// What Walk does after visiting a value.
+type WalkAction int
+
+const (
+	// Continues into the value and on to the rest.
+	WalkContinue WalkAction = iota
+	// Skips the values inside of the value and continues on to the rest.
+	WalkSkip
+	// Stops walking.
+	WalkStop
+)
+
+// An option which changes how Walk traverses a value.
+type WalkOption func(*walker)
+
+// Visits values at most depth nodes below the root. The root is at depth 0.
+func WalkMaxDepth(depth int) WalkOption {
+	return func(w *walker) {
+		w.maxDepth = depth
+	}
+}
+
+// Visits the values returned by getter methods as well as fields.
+func WalkMethods() WalkOption {
+	return func(w *walker) {
+		w.methods = true
+	}
+}
+
+// Calls fn with the root and every value reachable from it through exported
+// fields, map entries and slice or array elements, depth first. Map entries
+// are visited in key order. A value reached again through a pointer or map
+// which was already visited is skipped so cyclic values end.
+func Walk(root any, fn func(p Path, v reflect.Value) WalkAction, options ...WalkOption) {
+	rv := Reflect(root)
+	if !rv.IsValid() {
+		return
+	}
+	w := &walker{fn: fn, maxDepth: -1, visited: make(map[reference]bool)}
+	for _, option := range options {
+		option(w)
+	}
+	w.walk(NewPath(rv.Type()), rv, 0)
+}
+
+// The state of walking a value.
+type walker struct {
+	fn       func(p Path, v reflect.Value) WalkAction
+	maxDepth int
+	methods  bool
+	visited  map[reference]bool
+}
+
+// Visits the value and the values inside of it, returning false if walking stopped.
+func (w *walker) walk(path Path, rv reflect.Value, depth int) bool {
+	refs := references(rv)
+	for _, ref := range refs {
+		if w.visited[ref] {
+			return true
+		}
+	}
+	for _, ref := range refs {
+		w.visited[ref] = true
+	}
+
+	switch w.fn(path, rv) {
+	case WalkStop:
+		return false
+	case WalkSkip:
+		return true
+	}
+	if depth == w.maxDepth {
+		return true
+	}
+
+	for _, child := range children(rv, w.methods) {
+		value := getNode(child, rv)
+		if !value.IsValid() {
+			continue
+		}
+		if !w.walk(path.append(child), value, depth+1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package refstr
+
+import (
+	"reflect"
+	"testing"
+)
+
+type walkNode struct {
+	Name     string
+	Children []*walkNode
+	Attrs    map[string]int
+	Parent   *walkNode
+	hidden   int
+}
+
+func (n walkNode) Upper() string {
+	return n.Name + "!"
+}
+
+func TestWalk(t *testing.T) {
+	root := &walkNode{Name: "root", Attrs: map[string]int{"b": 2, "a": 1}}
+	child := &walkNode{Name: "child", Parent: root}
+	root.Children = []*walkNode{child}
+
+	tests := []struct {
+		name     string
+		options  []WalkOption
+		action   func(p Path) WalkAction
+		expected []string
+	}{{
+		name: "all",
+		expected: []string{
+			"", "Name", "Children", "Children[0]", "Children[0].Name", "Children[0].Children",
+			"Children[0].Attrs", "Attrs", "Attrs[a]", "Attrs[b]", "Parent",
+		},
+	}, {
+		name:     "max depth",
+		options:  []WalkOption{WalkMaxDepth(1)},
+		expected: []string{"", "Name", "Children", "Attrs", "Parent"},
+	}, {
+		name: "skip",
+		action: func(p Path) WalkAction {
+			if p.String() == "Children" {
+				return WalkSkip
+			}
+			return WalkContinue
+		},
+		expected: []string{"", "Name", "Children", "Attrs", "Attrs[a]", "Attrs[b]", "Parent"},
+	}, {
+		name: "stop",
+		action: func(p Path) WalkAction {
+			if p.String() == "Children[0].Name" {
+				return WalkStop
+			}
+			return WalkContinue
+		},
+		expected: []string{"", "Name", "Children", "Children[0]", "Children[0].Name"},
+	}, {
+		name:     "methods",
+		options:  []WalkOption{WalkMaxDepth(1), WalkMethods()},
+		expected: []string{"", "Name", "Children", "Attrs", "Parent", "Upper"},
+	}}
+
+	for _, test := range tests {
+		visited := make([]string, 0)
+		Walk(root, func(p Path, v reflect.Value) WalkAction {
+			visited = append(visited, p.String())
+			if test.action != nil {
+				return test.action(p)
+			}
+			return WalkContinue
+		}, test.options...)
+
+		if !StringEqual(visited, test.expected) {
+			t.Errorf("[%s] expected %v but got %v", test.name, test.expected, visited)
+		}
+	}
+}